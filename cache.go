@@ -0,0 +1,80 @@
+package main
+
+// cache.go
+//
+// market items are persisted to disk (when `cache_path` is set in config),
+// so that caches survive restarts
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+// persisted market items of a language
+type cachedItems struct {
+	Items   []a.MarketItem `json:"items"`
+	Updated time.Time      `json:"updated"`
+}
+
+var _cacheFileLock sync.Mutex // serializes writes of the cache file
+
+// load persisted market items into `_items` and `_itemsUpdated`
+//
+// (corrupt files and outdated items are ignored)
+func loadItemsCache() {
+	path := conf().CachePath
+	if path == "" {
+		return
+	}
+
+	cached := map[a.Lang]cachedItems{}
+	if err := loadJSONFile(path, &cached); err != nil {
+		if !os.IsNotExist(err) {
+			logWarnf("Failed to load cache of items: %s", err)
+		}
+
+		return
+	}
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	for language, c := range cached {
+		if len(c.Items) <= 0 || c.Updated.Add(cacheTTL()).Before(time.Now()) {
+			continue
+		}
+
+		_items[language] = c.Items
+		_itemsUpdated[language] = c.Updated
+
+		logInfof("Loaded %d cached item(s) of %s (updated at %s)", len(c.Items), language, c.Updated.UTC().Format(timestampFormat))
+	}
+}
+
+// persist current `_items` and `_itemsUpdated` to the cache file
+func saveItemsCache() {
+	path := conf().CachePath
+	if path == "" {
+		return
+	}
+
+	cached := map[a.Lang]cachedItems{}
+	_lock.RLock()
+	for language, items := range _items {
+		cached[language] = cachedItems{
+			Items:   items,
+			Updated: _itemsUpdated[language],
+		}
+	}
+	_lock.RUnlock()
+
+	_cacheFileLock.Lock()
+	defer _cacheFileLock.Unlock()
+
+	if err := saveJSONFile(path, cached); err != nil {
+		logErrorf("Failed to save cache of items: %s", err)
+	}
+}
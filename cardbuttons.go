@@ -0,0 +1,160 @@
+package main
+
+// cardbuttons.go
+//
+// inline keyboard buttons attached to the messages of inline query results,
+// so that recipients can refresh the price or see details of the card
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+	t "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	callbackDataCardPrefix = "card:" // "card:[action]:[hash name]"
+
+	cardActionRefresh = "refresh"
+	cardActionDetails = "details"
+
+	maxCallbackDataLength = 64 // limit of Telegram Bot API
+
+	messageCardRefreshEng       = "🔄 Refresh"
+	messageCardRefreshKor       = "🔄 새로고침"
+	messageCardDetailsEng       = "ℹ️ Details"
+	messageCardDetailsKor       = "ℹ️ 자세히"
+	messageCardNotFoundEng      = "This card is not in the market anymore."
+	messageCardNotFoundKor      = "장터에 더 이상 없는 카드입니다."
+	messageCardRefreshedEng     = "refreshed at %s"
+	messageCardRefreshedKor     = "%s에 새로고침됨"
+	messageCardDetailsFormatEng = `%s%s (%s)
+Price: %s (+ tax/fee %s = %s)
+Listings: %d
+Hero: %s
+%s`
+	messageCardDetailsFormatKor = `%s%s (%s)
+가격: %s (+ 세금/수수료 %s = %s)
+판매 등록: %d 건
+영웅: %s
+%s`
+	messageYesEng = "yes"
+	messageYesKor = "예"
+	messageNoEng  = "no"
+	messageNoKor  = "아니오"
+)
+
+// get message of given item for inline query results
+func inlineMessageOf(item a.MarketItem) string {
+	return fmt.Sprintf("%s (%s)\n%s\n%s", item.Name, item.AssetDescription.Type, item.SellPriceText, item.StoreURL())
+}
+
+// get detailed message of given item, with prices in currencies of given chat
+//
+// (texts of the item are escaped when the message is for Markdown)
+func detailedMessageOf(chatID int64, item a.MarketItem, language a.Lang, markdown bool) string {
+	currencies := currenciesOf(chatID)
+
+	price := float32(item.SellPrice) / 100.0
+	tax := taxOf(price)
+
+	hero := localized(language, messageNoEng, messageNoKor)
+	if isHero(item.Name, language) {
+		hero = localized(language, messageYesEng, messageYesKor)
+	}
+
+	name, itemType := item.Name, item.AssetDescription.Type
+	if markdown {
+		name, itemType = escapeMarkdown(name), escapeMarkdown(itemType)
+	}
+
+	return fmt.Sprintf(localized(language, messageCardDetailsFormatEng, messageCardDetailsFormatKor),
+		rarityEmoji(rarityOf(item, language)), name, itemType,
+		formatPrices(price, currencies), formatPrices(tax, currencies), formatPrices(price+tax, currencies),
+		item.SellListings,
+		hero,
+		item.StoreURL(),
+	)
+}
+
+// generate inline keyboard for the message of given item
+//
+// (returns nil when the hash name is too long for callback data)
+func cardButtonsOf(item a.MarketItem, language a.Lang) *t.InlineKeyboardMarkup {
+	refresh, details := cardCallbackData(cardActionRefresh, item.HashName), cardCallbackData(cardActionDetails, item.HashName)
+	if len(refresh) > maxCallbackDataLength || len(details) > maxCallbackDataLength {
+		return nil
+	}
+
+	return &t.InlineKeyboardMarkup{
+		InlineKeyboard: [][]t.InlineKeyboardButton{
+			[]t.InlineKeyboardButton{
+				callbackButton(localized(language, messageCardRefreshEng, messageCardRefreshKor), refresh),
+				callbackButton(localized(language, messageCardDetailsEng, messageCardDetailsKor), details),
+			},
+		},
+	}
+}
+
+// generate callback data for given action on a card
+func cardCallbackData(action, hashName string) string {
+	return fmt.Sprintf("%s%s:%s", callbackDataCardPrefix, action, hashName)
+}
+
+// get item with given hash name
+func itemByHashName(hashName string, language a.Lang) (a.MarketItem, bool) {
+	for _, item := range getItems(language) {
+		if item.HashName == hashName {
+			return item, true
+		}
+	}
+
+	return a.MarketItem{}, false
+}
+
+// process callback query of buttons on a card
+func processCardCallbackQuery(b messageSender, query *t.CallbackQuery, data string, chatID int64, language a.Lang) bool {
+	splitted := strings.SplitN(strings.TrimPrefix(data, callbackDataCardPrefix), ":", 2)
+	if len(splitted) != 2 || query.InlineMessageID == nil {
+		logWarnf("Malformed callback query: %s", data)
+
+		b.AnswerCallbackQuery(query.ID, nil)
+		return false
+	}
+	action, hashName := splitted[0], splitted[1]
+
+	item, exists := itemByHashName(hashName, language)
+	if !exists {
+		b.AnswerCallbackQuery(query.ID, t.OptionsAnswerCallbackQuery{}.
+			SetText(localized(language, messageCardNotFoundEng, messageCardNotFoundKor)))
+		return false
+	}
+
+	var message string
+	switch action {
+	case cardActionDetails:
+		message = detailedMessageOf(chatID, item, language, false)
+	default: // cardActionRefresh
+		refreshed := fmt.Sprintf(localized(language, messageCardRefreshedEng, messageCardRefreshedKor), time.Now().UTC().Format(timestampFormat))
+		message = fmt.Sprintf("%s\n(%s)", inlineMessageOf(item), refreshed)
+	}
+
+	options := t.OptionsEditMessageText{}.
+		SetInlineMessageID(*query.InlineMessageID).
+		SetDisableWebPagePreview(!conf().ShowWebPagePreviews)
+	if buttons := cardButtonsOf(item, language); buttons != nil {
+		options.SetReplyMarkup(*buttons)
+	}
+	edited := b.EditMessageText(message, options)
+
+	b.AnswerCallbackQuery(query.ID, nil)
+
+	if !edited.Ok {
+		logErrorf("Failed to edit inline message: %s", descriptionOf(edited.APIResponseBase))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,220 @@
+package main
+
+// chart.go
+//
+// render price history as line charts
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	chartWidth        = 960
+	chartHeight       = 540
+	chartMarginLeft   = 110
+	chartMarginRight  = 40
+	chartMarginTop    = 70
+	chartMarginBottom = 70
+	chartTitleSize    = 24
+	chartLabelSize    = 16
+	chartLineWidth    = 3
+
+	chartDateFormat = "01/02 15:04"
+
+	// default window of trend charts
+	defaultTrendWindow = 7 * 24 * time.Hour
+
+	// min number of history entries for drawing a trend chart
+	minNumTrendEntries = 2
+
+	// max and min numbers of recent prices in a sparkline
+	numSparklinePoints    = 12
+	minNumSparklinePoints = 3
+)
+
+// levels of sparklines, from the lowest to the highest
+var sparklineLevels = []rune("▁▂▃▄▅▆▇█")
+
+var (
+	chartBackgroundColor = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartAxisColor       = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	chartGridColor       = color.RGBA{0xee, 0xee, 0xee, 0xff}
+	chartLineColor       = color.RGBA{0x29, 0x80, 0xb9, 0xff}
+	chartTextColor       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+)
+
+// parse window of trend chart (eg. "24h", "7d"), default: `defaultTrendWindow`
+func parseTrendWindow(arg string) (time.Duration, error) {
+	arg = strings.ToLower(strings.TrimSpace(arg))
+	if arg == "" {
+		return defaultTrendWindow, nil
+	}
+
+	var window time.Duration
+	if strings.HasSuffix(arg, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(arg, "d"))
+		if err != nil {
+			return 0, err
+		}
+		window = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if window, err = time.ParseDuration(arg); err != nil {
+			return 0, err
+		}
+	}
+
+	if window <= 0 {
+		return 0, fmt.Errorf("not a positive window: %s", arg)
+	}
+
+	return window, nil
+}
+
+// render a line chart of the full collection's price in given history entries
+func renderTrendChart(entries []historyEntry, title string, language a.Lang) ([]byte, error) {
+	if len(entries) < minNumTrendEntries || !entries[len(entries)-1].Time.After(entries[0].Time) {
+		return nil, fmt.Errorf("not enough history entries: %d", len(entries))
+	}
+
+	parsed, err := loadImageFont()
+	if err != nil {
+		return nil, err
+	}
+	titleFace := truetype.NewFace(parsed, &truetype.Options{Size: chartTitleSize})
+	labelFace := truetype.NewFace(parsed, &truetype.Options{Size: chartLabelSize})
+
+	// ranges of values
+	from, to := entries[0].Time, entries[len(entries)-1].Time
+	min, max := math.MaxInt32, 0
+	for _, entry := range entries {
+		if entry.Collection < min {
+			min = entry.Collection
+		}
+		if entry.Collection > max {
+			max = entry.Collection
+		}
+	}
+	if min == max { // flat line in the middle
+		min, max = min-1, max+1
+	}
+
+	plot := image.Rect(chartMarginLeft, chartMarginTop, chartWidth-chartMarginRight, chartHeight-chartMarginBottom)
+	xOf := func(t time.Time) int {
+		return plot.Min.X + int(float64(plot.Dx())*float64(t.Sub(from))/float64(to.Sub(from)))
+	}
+	yOf := func(price int) int {
+		return plot.Max.Y - int(float64(plot.Dy())*float64(price-min)/float64(max-min))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartBackgroundColor), image.ZP, draw.Src)
+
+	// grid and axes
+	for i := 1; i < 4; i++ {
+		y := plot.Min.Y + plot.Dy()*i/4
+		drawLine(img, plot.Min.X, y, plot.Max.X, y, 1, chartGridColor)
+	}
+	drawLine(img, plot.Min.X, plot.Min.Y, plot.Min.X, plot.Max.Y, 1, chartAxisColor)
+	drawLine(img, plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y, 1, chartAxisColor)
+
+	// prices
+	for i := 1; i < len(entries); i++ {
+		drawLine(img,
+			xOf(entries[i-1].Time), yOf(entries[i-1].Collection),
+			xOf(entries[i].Time), yOf(entries[i].Collection),
+			chartLineWidth, chartLineColor)
+	}
+
+	// texts
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(chartTextColor), Face: titleFace}
+	drawer.Dot = fixed.P((chartWidth-drawer.MeasureString(title).Round())/2, chartMarginTop/2+chartTitleSize/2)
+	drawer.DrawString(title)
+
+	drawer.Face = labelFace
+	drawText := func(text string, x, y int, alignRight bool) {
+		if alignRight {
+			x -= drawer.MeasureString(text).Round()
+		}
+		drawer.Dot = fixed.P(x, y)
+		drawer.DrawString(text)
+	}
+	labelGap := chartLabelSize / 2
+	drawText(formatMoney(float32(max)/100.0, currencyUSD), plot.Min.X-labelGap, plot.Min.Y+chartLabelSize/2, true)
+	drawText(formatMoney(float32(min)/100.0, currencyUSD), plot.Min.X-labelGap, plot.Max.Y+chartLabelSize/2, true)
+	drawText(from.UTC().Format(chartDateFormat), plot.Min.X, plot.Max.Y+chartLabelSize+labelGap, false)
+	drawText(to.UTC().Format(chartDateFormat), plot.Max.X, plot.Max.Y+chartLabelSize+labelGap, true)
+	drawText(localized(language, messageTrendChartXAxisEng, messageTrendChartXAxisKor), plot.Min.X+plot.Dx()/2-chartLabelSize, chartHeight-labelGap*2, false)
+	drawText(localized(language, messageTrendChartYAxisEng, messageTrendChartYAxisKor), labelGap, plot.Min.Y-chartLabelSize, false)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// render given values as a sparkline (eg. "▁▂▄▅▇")
+//
+// (returns an empty string when there are not enough values)
+func sparklineOf(values []int) string {
+	if len(values) < minNumSparklinePoints {
+		return ""
+	}
+
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+
+	levels := make([]rune, len(values))
+	for i, v := range values {
+		level := len(sparklineLevels) / 2 // flat line in the middle
+		if max > min {
+			level = (v - min) * (len(sparklineLevels) - 1) / (max - min)
+		}
+		levels[i] = sparklineLevels[level]
+	}
+
+	return string(levels)
+}
+
+// draw a line with given width and color
+func drawLine(img *image.RGBA, x0, y0, x1, y1, width int, c color.Color) {
+	steps := int(math.Max(math.Abs(float64(x1-x0)), math.Abs(float64(y1-y0))))
+	if steps == 0 {
+		steps = 1
+	}
+
+	for i := 0; i <= steps; i++ {
+		x := x0 + (x1-x0)*i/steps
+		y := y0 + (y1-y0)*i/steps
+
+		for dx := -width / 2; dx <= width/2; dx++ {
+			for dy := -width / 2; dy <= width/2; dy++ {
+				img.Set(x+dx, y+dy, c)
+			}
+		}
+	}
+}
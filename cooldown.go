@@ -0,0 +1,81 @@
+package main
+
+// cooldown.go
+//
+// cooldowns of commands per user, and suppression of duplicate commands per chat
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	duplicateCommandWindowSeconds = 2 // same commands in a chat within this time are considered duplicates
+)
+
+// last command received in a chat
+type lastCommand struct {
+	txt  string
+	time time.Time
+}
+
+var _cooldowns = map[string]time.Time{} // "command:user id" => time until which the command is cooling down
+var _cooldownsLock sync.Mutex
+var _lastCommands = map[int64]lastCommand{} // chat id => last command
+var _lastCommandsLock sync.Mutex
+
+// check cooldown of given command for given user, and start it when the command is not cooling down
+//
+// returns remaining cooldown (zero when the command can be used)
+func checkCooldown(command string, userID int64) time.Duration {
+	seconds, exists := conf().CommandCooldownSeconds[command]
+	if !exists || seconds <= 0 {
+		return 0
+	}
+
+	_cooldownsLock.Lock()
+	defer _cooldownsLock.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf("%s:%d", command, userID)
+	if until, exists := _cooldowns[key]; exists && now.Before(until) {
+		return until.Sub(now)
+	}
+
+	// remove expired ones
+	for k, until := range _cooldowns {
+		if !now.Before(until) {
+			delete(_cooldowns, k)
+		}
+	}
+
+	_cooldowns[key] = now.Add(time.Duration(seconds) * time.Second)
+
+	return 0
+}
+
+// check if given command text is the same as the last one in given chat, received just before
+//
+// (also records given command text as the last one)
+func isDuplicateCommand(chatID int64, txt string) bool {
+	_lastCommandsLock.Lock()
+	defer _lastCommandsLock.Unlock()
+
+	now := time.Now()
+	window := duplicateCommandWindowSeconds * time.Second
+
+	last, exists := _lastCommands[chatID]
+	duplicate := exists && last.txt == txt && now.Sub(last.time) < window
+
+	// remove expired ones
+	for id, last := range _lastCommands {
+		if now.Sub(last.time) >= window {
+			delete(_lastCommands, id)
+		}
+	}
+
+	_lastCommands[chatID] = lastCommand{txt: txt, time: now}
+
+	return duplicate
+}
@@ -0,0 +1,243 @@
+package main
+
+// currency.go
+//
+// prices in other currencies than USD, converted with exchange rates which are fetched and cached
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// default endpoint of exchange rates (based on USD)
+	defaultExchangeRatesURL = "https://open.er-api.com/v6/latest/USD"
+
+	// ttl of exchange rates
+	exchangeRatesCacheHours = 24
+
+	// timeout of fetching exchange rates
+	exchangeRatesTimeoutSeconds = 10
+
+	// backoff after a failure of fetching exchange rates
+	exchangeRatesRetryMinutes = 5
+
+	// max number of currencies displayed simultaneously
+	maxNumCurrencies = 3
+
+	// base currency of market prices
+	currencyUSD = "USD"
+)
+
+// response from exchange rates endpoint
+type exchangeRates struct {
+	Rates map[string]float32 `json:"rates"` // currency code => rate (1 USD = rate)
+}
+
+var _rates map[string]float32
+var _ratesUpdated time.Time
+var _ratesFailed time.Time
+var _ratesLock sync.Mutex
+
+// error for users when exchange rates cannot be fetched (actual errors are logged)
+var errExchangeRatesUnavailable = errors.New("exchange rates are unavailable")
+
+// currency symbols for formatting prices
+var _currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"KRW": "₩",
+	// TODO - add more currency symbols here
+}
+
+// currencies without minor units
+var _currenciesWithoutDecimals = map[string]bool{
+	"JPY": true,
+	"KRW": true,
+}
+
+// get exchange rates, fetching them when outdated
+//
+// (stale rates are returned when fetching fails, and failures are retried after a backoff)
+func getExchangeRates() (map[string]float32, error) {
+	_ratesLock.Lock()
+	rates, updated, failed := _rates, _ratesUpdated, _ratesFailed
+	_ratesLock.Unlock()
+
+	if rates != nil && time.Since(updated) < exchangeRatesCacheHours*time.Hour {
+		return rates, nil
+	}
+	if time.Since(failed) < exchangeRatesRetryMinutes*time.Minute {
+		return staleExchangeRates(rates)
+	}
+
+	// (fetch without holding the lock, so that a slow endpoint does not block others)
+	fetched, err := fetchExchangeRates()
+
+	_ratesLock.Lock()
+	defer _ratesLock.Unlock()
+
+	if err != nil {
+		logWarnf("failed to fetch exchange rates: %s", err)
+
+		_ratesFailed = time.Now()
+
+		return staleExchangeRates(_rates)
+	}
+
+	_rates = fetched
+	_ratesUpdated = time.Now()
+	_ratesFailed = time.Time{}
+
+	return _rates, nil
+}
+
+// get stale exchange rates, or an error if there is none
+func staleExchangeRates(rates map[string]float32) (map[string]float32, error) {
+	if rates != nil {
+		return rates, nil
+	}
+
+	return nil, errExchangeRatesUnavailable
+}
+
+// fetch exchange rates from the endpoint in config
+func fetchExchangeRates() (map[string]float32, error) {
+	url := conf().ExchangeRatesURL
+	if url == "" {
+		url = defaultExchangeRatesURL
+	}
+
+	client := http.Client{Timeout: exchangeRatesTimeoutSeconds * time.Second}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %d from %s", res.StatusCode, url)
+	}
+
+	var rates exchangeRates
+	if err := json.NewDecoder(res.Body).Decode(&rates); err != nil {
+		return nil, err
+	}
+	if len(rates.Rates) <= 0 {
+		return nil, fmt.Errorf("no exchange rates from %s", url)
+	}
+	rates.Rates[currencyUSD] = 1.0
+
+	return rates.Rates, nil
+}
+
+// convert given price in USD to given currency
+func convertPrice(dollars float32, currency string) (float32, error) {
+	if currency == currencyUSD {
+		return dollars, nil
+	}
+
+	rates, err := getExchangeRates()
+	if err != nil {
+		return 0, err
+	}
+
+	if rate, exists := rates[currency]; exists {
+		return dollars * rate, nil
+	}
+
+	return 0, fmt.Errorf("no exchange rate for currency: %s", currency)
+}
+
+// convert given amount of money between given currencies
+func convertMoney(amount float32, from, to string) (float32, error) {
+	// 1 USD = rate
+	fromRate, err := convertPrice(1, from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := convertPrice(1, to)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount / fromRate * toRate, nil
+}
+
+// format given amount of money in given currency
+func formatMoney(amount float32, currency string) string {
+	format := "%.2f"
+	if _currenciesWithoutDecimals[currency] {
+		format = "%.0f"
+	}
+
+	if symbol, exists := _currencySymbols[currency]; exists {
+		return symbol + fmt.Sprintf(format, amount)
+	}
+
+	return fmt.Sprintf(format+" %s", amount, currency)
+}
+
+// get default currency for chats which did not set their own (`currency` in config, or USD)
+func defaultCurrency() string {
+	if currency := strings.ToUpper(conf().Currency); currency != "" {
+		return currency
+	}
+
+	return currencyUSD
+}
+
+// format given price in USD in all given currencies (default currency when none is given)
+func formatPrices(dollars float32, currencies []string) string {
+	if len(currencies) <= 0 {
+		currencies = []string{defaultCurrency()}
+	}
+
+	formatted := []string{}
+	for _, currency := range currencies {
+		if converted, err := convertPrice(dollars, currency); err == nil {
+			formatted = append(formatted, formatMoney(converted, currency))
+		} else {
+			logWarnf("Failed to convert price to %s: %s", currency, err)
+		}
+	}
+
+	// fallback to USD
+	if len(formatted) <= 0 {
+		return formatMoney(dollars, currencyUSD)
+	}
+
+	return strings.Join(formatted, " / ")
+}
+
+// parse and validate comma-separated currency codes
+func parseCurrencies(txt string) (currencies []string, err error) {
+	for _, code := range strings.Split(txt, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+
+		if _, err := convertPrice(1, code); err != nil {
+			return nil, err
+		}
+
+		currencies = append(currencies, code)
+	}
+
+	if len(currencies) <= 0 {
+		return nil, fmt.Errorf("no currency was given")
+	}
+	if len(currencies) > maxNumCurrencies {
+		return nil, fmt.Errorf("too many currencies (max: %d)", maxNumCurrencies)
+	}
+
+	return currencies, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+// clear cached exchange rates and failures
+func clearExchangeRates() {
+	_ratesLock.Lock()
+	_rates, _ratesUpdated, _ratesFailed = nil, time.Time{}, time.Time{}
+	_ratesLock.Unlock()
+}
+
+// test that failures of fetching exchange rates are cached, and not shown to users as they are
+func TestExchangeRatesFailure(test *testing.T) {
+	var numRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	setUpTest(test, config{ExchangeRatesURL: server.URL}, _testItems)
+	clearExchangeRates()
+	defer clearExchangeRates()
+
+	if _, err := convertPrice(1, "KRW"); err != errExchangeRatesUnavailable {
+		test.Errorf("expected unavailable exchange rates, got: %v", err)
+	}
+
+	reply := getConversion("10 USD KRW", a.LangEnglish)
+	if strings.Contains(reply, server.URL) || !strings.Contains(reply, "try again later") {
+		test.Errorf("expected a generic error, got: %s", reply)
+	}
+	if reply := setCurrenciesWith(testChatID, "KRW", a.LangEnglish); !strings.Contains(reply, "try again later") {
+		test.Errorf("expected a generic error, got: %s", reply)
+	}
+
+	if n := atomic.LoadInt32(&numRequests); n != 1 {
+		test.Errorf("expected 1 request during the backoff, got %d", n)
+	}
+}
+
+// test that stale exchange rates are used when fetching fails
+func TestStaleExchangeRates(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	setUpTest(test, config{ExchangeRatesURL: server.URL}, _testItems)
+	clearExchangeRates()
+	defer clearExchangeRates()
+
+	_ratesLock.Lock()
+	_rates = map[string]float32{currencyUSD: 1.0, "KRW": 1000.0}
+	_ratesUpdated = time.Now().Add(-(exchangeRatesCacheHours + 1) * time.Hour)
+	_ratesLock.Unlock()
+
+	if converted, err := convertPrice(2, "KRW"); err != nil || converted != 2000.0 {
+		test.Errorf("expected stale rates to be used, got: %f, %v", converted, err)
+	}
+}
+
+// test that invalid amounts of conversions are rejected with the usage
+func TestConversionWithInvalidAmounts(test *testing.T) {
+	setUpTest(test, config{}, nil)
+
+	usage := getConversion("", a.LangEnglish)
+	for _, amount := range []string{"NaN", "nan", "Inf", "-Inf", "-1", "1e40"} {
+		if reply := getConversion(amount+" USD KRW", a.LangEnglish); reply != usage {
+			test.Errorf("expected usage for amount '%s', got: %s", amount, reply)
+		}
+	}
+}
+
+// test that detailed messages of cards show prices in currencies of the chat
+func TestDetailedMessageInCurrencies(test *testing.T) {
+	setUpTest(test, config{}, _testItems)
+	clearExchangeRates()
+	defer clearExchangeRates()
+
+	_ratesLock.Lock()
+	_rates = map[string]float32{currencyUSD: 1.0, "KRW": 1000.0}
+	_ratesUpdated = time.Now()
+	_ratesLock.Unlock()
+
+	setCurrencies(testChatID, []string{"KRW"})
+
+	item := _testItems[0]
+	expected := formatMoney(float32(item.SellPrice)/100.0*1000.0, "KRW")
+	if message := detailedMessageOf(testChatID, item, a.LangEnglish, true); !strings.Contains(message, expected) {
+		test.Errorf("expected price '%s' in the message, got: %s", expected, message)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+// deck.go
+//
+// market costs of decks, which are given as lists of card names with optional counts
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	// max number of entries in a deck list
+	maxNumDeckEntries = 60
+
+	messageDeckCostUsageEng   = "Usage: %s [card names, separated by commas or newlines]\n(e.g. %s Axe, 3x Thunderhide Pack)"
+	messageDeckCostUsageKor   = "사용법: %s [쉼표나 줄바꿈으로 구분된 카드 이름들]\n(예: %s 도끼, 3x [카드 이름])"
+	messageDeckCostTooManyEng = "Only up to %d entries can be calculated at once."
+	messageDeckCostTooManyKor = "한 번에 최대 %d개 항목까지 계산할 수 있습니다."
+	messageDeckCostEng        = `*Deck cost:*
+
+%s
+----
+Total (%d cards): *%s* (+ tax/fee %s = *%s*)%s`
+	messageDeckCostKor = `*덱 비용:*
+
+%s
+----
+합계 (%d 장): *%s* (+ 세금/수수료 %s = *%s*)%s`
+	messageDeckCostLineEng      = "- %s × %d: %s"
+	messageDeckCostLineKor      = "- %s × %d장: %s"
+	messageDeckCostCappedEng    = " _(capped at %d)_"
+	messageDeckCostCappedKor    = " _(최대 %d장)_"
+	messageDeckCostNoneEng      = "No card in the list was found in the market."
+	messageDeckCostNoneKor      = "목록의 카드를 장터에서 찾을 수 없습니다."
+	messageDeckCostUnmatchedEng = "\n\n_Not found:_ %s"
+	messageDeckCostUnmatchedKor = "\n\n_찾을 수 없음:_ %s"
+	messageDeckCostAmbiguousEng = "\n\n_Matching multiple cards (please be more specific):_ %s"
+	messageDeckCostAmbiguousKor = "\n\n_여러 카드에 해당 (더 정확하게 입력해 주세요):_ %s"
+)
+
+// pattern of a deck entry with a count (eg. "3x Axe", "2 x Bristleback")
+var _deckEntryWithCountPattern = regexp.MustCompile(`^(\d+)\s*[xX×]\s*(.+)$`)
+
+// a card and its count in a deck
+type deckEntry struct {
+	item   a.MarketItem
+	count  int
+	capped bool
+}
+
+// parse given deck list into card names and their counts
+//
+// (entries are separated by commas or newlines, and can be prefixed with counts like "3x")
+func parseDeckList(list string) (names []string, counts []int) {
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		entry = strings.TrimSpace(entry)
+
+		count := 1
+		if matches := _deckEntryWithCountPattern.FindStringSubmatch(entry); len(matches) == 3 {
+			if n, err := strconv.Atoi(matches[1]); err == nil && n > 0 {
+				count, entry = n, matches[2]
+			}
+		}
+
+		if name := sanitizeQuery(entry); name != "" {
+			names = append(names, name)
+			counts = append(counts, count)
+		}
+	}
+
+	return names, counts
+}
+
+// get the market cost of a deck in given command argument
+func getDeckCost(chatID int64, arg string, language a.Lang) string {
+	names, counts := parseDeckList(arg)
+	if len(names) <= 0 {
+		return fmt.Sprintf(localized(language, messageDeckCostUsageEng, messageDeckCostUsageKor), commandDeckCost, commandDeckCost)
+	}
+	if len(names) > maxNumDeckEntries {
+		return fmt.Sprintf(localized(language, messageDeckCostTooManyEng, messageDeckCostTooManyKor), maxNumDeckEntries)
+	}
+
+	// (entries of the same card are merged before being capped)
+	entries := []deckEntry{}
+	indices := map[string]int{} // hash name => index in `entries`
+	unmatched, ambiguous := []string{}, []string{}
+	for i, name := range names {
+		items := searchItemsByName(name, language)
+		item, found := singleItemOf(items, name)
+		if !found {
+			if len(items) <= 0 {
+				unmatched = append(unmatched, escapeMarkdown(name))
+			} else {
+				ambiguous = append(ambiguous, escapeMarkdown(name))
+			}
+			continue
+		}
+
+		if index, exists := indices[item.HashName]; exists {
+			entries[index].count += counts[i]
+		} else {
+			indices[item.HashName] = len(entries)
+			entries = append(entries, deckEntry{item: item, count: counts[i]})
+		}
+	}
+	for i, entry := range entries {
+		if max := numCardsOf(entry.item, language, collectionModePlayset); entry.count > max {
+			entries[i].count, entries[i].capped = max, true
+		}
+	}
+
+	unresolved := ""
+	if len(unmatched) > 0 {
+		unresolved += fmt.Sprintf(localized(language, messageDeckCostUnmatchedEng, messageDeckCostUnmatchedKor), strings.Join(unmatched, ", "))
+	}
+	if len(ambiguous) > 0 {
+		unresolved += fmt.Sprintf(localized(language, messageDeckCostAmbiguousEng, messageDeckCostAmbiguousKor), strings.Join(ambiguous, ", "))
+	}
+	if len(entries) <= 0 {
+		return localized(language, messageDeckCostNoneEng, messageDeckCostNoneKor) + unresolved
+	}
+
+	currencies := currenciesOf(chatID)
+
+	lines := []string{}
+	numCards, sum := 0, 0
+	for _, entry := range entries {
+		price := entry.item.SellPrice * entry.count
+
+		line := fmt.Sprintf(localized(language, messageDeckCostLineEng, messageDeckCostLineKor),
+			escapeMarkdown(entry.item.Name), entry.count, formatPrices(float32(price)/100.0, currencies))
+		if entry.capped {
+			line += fmt.Sprintf(localized(language, messageDeckCostCappedEng, messageDeckCostCappedKor), entry.count)
+		}
+		lines = append(lines, line)
+
+		numCards += entry.count
+		sum += price
+	}
+
+	total := float32(sum) / 100.0
+	tax := taxOf(total)
+
+	return fmt.Sprintf(localized(language, messageDeckCostEng, messageDeckCostKor),
+		strings.Join(lines, "\n"),
+		numCards,
+		formatPrices(total, currencies), formatPrices(tax, currencies), formatPrices(total+tax, currencies),
+		unresolved,
+	)
+}
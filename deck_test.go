@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+// test parsing deck lists
+func TestParseDeckList(test *testing.T) {
+	names, counts := parseDeckList("Axe, 3x Thunderhide Pack\n2 x Keefe the Bold,, ")
+
+	expectedNames, expectedCounts := []string{"Axe", "Thunderhide Pack", "Keefe the Bold"}, []int{1, 3, 2}
+	if strings.Join(names, "|") != strings.Join(expectedNames, "|") {
+		test.Errorf("expected names %v, got %v", expectedNames, names)
+	}
+	for i, count := range counts {
+		if i >= len(expectedCounts) || count != expectedCounts[i] {
+			test.Errorf("expected counts %v, got %v", expectedCounts, counts)
+			break
+		}
+	}
+}
+
+// test that duplicate entries are merged before being capped, and ambiguous names are reported
+func TestDeckCost(test *testing.T) {
+	setUpTest(test, config{}, _testItems)
+
+	cost := getDeckCost(testChatID, "Thunderhide Pack, 3x thunderhide pack, 2x Keefe the Bold, e, nothing like this", a.LangEnglish)
+
+	for _, expected := range []string{
+		"Thunderhide Pack × 3: $0.60 _(capped at 3)_",
+		"Keefe the Bold × 1: $0.05 _(capped at 1)_", // (hero)
+		"Total (4 cards)",
+		"_Not found:_ nothing like this",
+		"_Matching multiple cards (please be more specific):_ e",
+	} {
+		if !strings.Contains(cost, expected) {
+			test.Errorf("expected '%s' in: %s", expected, cost)
+		}
+	}
+	if strings.Count(cost, "Thunderhide Pack") != 1 {
+		test.Errorf("expected duplicate entries to be merged: %s", cost)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+// export.go
+//
+// market items are exported as CSV, JSON, or JSON lines documents
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+// export formats
+type exportFormat string
+
+const (
+	exportFormatJSON      exportFormat = "json"
+	exportFormatJSONLines exportFormat = "jsonl"
+	exportFormatCSV       exportFormat = "csv"
+)
+
+// export given items in given format
+func exportItems(items []a.MarketItem, format exportFormat) ([]byte, error) {
+	switch format {
+	case exportFormatJSON:
+		return json.MarshalIndent(items, "", "  ")
+	case exportFormatJSONLines:
+		// one item per line
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for _, item := range items {
+			if err := encoder.Encode(item); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	case exportFormatCSV:
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write([]string{"name", "hash_name", "type", "sell_price", "sell_price_text", "icon_url", "store_url"})
+		for _, item := range items {
+			writer.Write([]string{
+				item.Name,
+				item.HashName,
+				item.AssetDescription.Type,
+				strconv.Itoa(item.SellPrice),
+				item.SellPriceText,
+				item.AssetDescription.IconURL(),
+				item.StoreURL(),
+			})
+		}
+		writer.Flush()
+		return buf.Bytes(), writer.Error()
+	}
+
+	return nil, fmt.Errorf("unsupported export format: %s", format)
+}
+
+// get filename of exported items
+func exportFilename(language a.Lang, format exportFormat) string {
+	return fmt.Sprintf("artifact-market-%s-%s.%s", language, time.Now().UTC().Format("20060102-150405"), format)
+}
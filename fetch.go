@@ -0,0 +1,136 @@
+package main
+
+// fetch.go
+//
+// fetches of market items are serialized and spaced, so that refreshes of
+// caches of multiple languages do not hit Steam at once,
+// and concurrent fetches of the same language are coalesced into one
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	// min interval between fetches of market items
+	minFetchIntervalSeconds = 3
+
+	// default number of retries of failed fetches
+	defaultFetchRetries = 3
+
+	// delay before the first retry (doubled for each retry)
+	fetchRetryBaseDelaySeconds = 1
+)
+
+// source of market items
+//
+// (Steam Community Market, or fakes in tests)
+type itemsSource interface {
+	FetchAll(rarity a.Rarity, language a.Lang, column a.SortColumn, direction a.SortDirection) ([]a.MarketItem, error)
+}
+
+// Steam Community Market as a source of market items
+type steamMarket struct{}
+
+// fetch all market items from Steam Community Market
+func (steamMarket) FetchAll(rarity a.Rarity, language a.Lang, column a.SortColumn, direction a.SortDirection) ([]a.MarketItem, error) {
+	return a.FetchAll(rarity, language, column, direction)
+}
+
+var _itemsSource itemsSource = steamMarket{}
+
+// an in-flight fetch of market items
+type fetchCall struct {
+	done  chan struct{}
+	items []a.MarketItem
+	err   error
+}
+
+var _fetchLock sync.Mutex // serializes fetches
+var _lastFetched time.Time
+var _fetchCalls = map[a.Lang]*fetchCall{} // in-flight fetches
+var _fetchCallsLock sync.Mutex
+
+// fetch market items of given language
+//
+// (when a fetch of the same language is in flight, waits for it and shares its result)
+//
+// `onFetched` is called only once per fetch, with successfully fetched items
+func fetchItems(language a.Lang, onFetched func(items []a.MarketItem)) ([]a.MarketItem, error) {
+	_fetchCallsLock.Lock()
+	if call, exists := _fetchCalls[language]; exists {
+		_fetchCallsLock.Unlock()
+
+		<-call.done
+
+		return call.items, call.err
+	}
+	call := &fetchCall{done: make(chan struct{}), err: errors.New("fetch was aborted")}
+	_fetchCalls[language] = call
+	_fetchCallsLock.Unlock()
+
+	// (cleaned up even when `onFetched` panics, so that later fetches do not wait forever)
+	defer func() {
+		_fetchCallsLock.Lock()
+		delete(_fetchCalls, language)
+		_fetchCallsLock.Unlock()
+
+		close(call.done)
+	}()
+
+	items, err := fetchItemsWithRetries(language)
+	if err == nil {
+		onFetched(items)
+	}
+	call.items, call.err = items, err
+
+	return items, err
+}
+
+// get number of retries of failed fetches (`fetch_retries` in config, or `defaultFetchRetries`)
+//
+// (0 means no retries)
+func fetchRetries() int {
+	if retries := conf().FetchRetries; retries != nil {
+		return *retries
+	}
+
+	return defaultFetchRetries
+}
+
+// fetch market items of given language, retrying with exponential backoff on failures
+//
+// (backoffs do not hold `_fetchLock`, so fetches of other languages can proceed meanwhile)
+func fetchItemsWithRetries(language a.Lang) (items []a.MarketItem, err error) {
+	retries := fetchRetries()
+	delay := fetchRetryBaseDelaySeconds * time.Second
+
+	for attempt := 0; ; attempt++ {
+		if items, err = fetchItemsSerially(language); err == nil || attempt >= retries {
+			return items, err
+		}
+
+		logWarnf("Failed to fetch items (%s), retrying in %s (%d/%d): %s", language, delay, attempt+1, retries, err)
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// fetch market items of given language, one fetch at a time and at least `minFetchIntervalSeconds` apart
+func fetchItemsSerially(language a.Lang) ([]a.MarketItem, error) {
+	_fetchLock.Lock()
+	defer _fetchLock.Unlock()
+
+	if wait := time.Until(_lastFetched.Add(minFetchIntervalSeconds * time.Second)); wait > 0 {
+		time.Sleep(wait)
+	}
+	defer func() {
+		_lastFetched = time.Now()
+	}()
+
+	return _itemsSource.FetchAll(a.RarityAll, language, a.SortColumnName, a.SortDirectionAsc)
+}
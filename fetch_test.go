@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+// test that a panic in the callback of a fetch does not block later fetches of the same language
+func TestFetchItemsAfterPanic(test *testing.T) {
+	setUpTest(test, config{}, nil)
+	_itemsSource = fakeSource{items: map[a.Lang][]a.MarketItem{a.LangEnglish: _testItems}}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				test.Errorf("expected a panic from the callback")
+			}
+		}()
+
+		fetchItems(a.LangEnglish, func(items []a.MarketItem) {
+			panic("panic in callback")
+		})
+	}()
+
+	resetFetchSpacing()
+
+	done := make(chan []a.MarketItem)
+	go func() {
+		items, _ := fetchItems(a.LangEnglish, func(items []a.MarketItem) {})
+		done <- items
+	}()
+
+	select {
+	case items := <-done:
+		if len(items) != len(_testItems) {
+			test.Errorf("expected %d items, got %d", len(_testItems), len(items))
+		}
+	case <-time.After(5 * time.Second):
+		test.Fatalf("fetch after a panic was blocked")
+	}
+}
+
+// test that `fetch_retries: 0` disables retries instead of falling back to the default
+func TestFetchRetries(test *testing.T) {
+	setUpTest(test, config{}, nil)
+
+	if retries := fetchRetries(); retries != defaultFetchRetries {
+		test.Errorf("expected %d retries by default, got %d", defaultFetchRetries, retries)
+	}
+
+	zero := 0
+	setUpTest(test, config{FetchRetries: &zero}, nil)
+
+	if retries := fetchRetries(); retries != 0 {
+		test.Errorf("expected no retries, got %d", retries)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+// test summary of recorded items, fetched through the source of market items
+func TestGetSummaryWithFixture(test *testing.T) {
+	items := loadFixtureItems(test)
+	setUpTest(test, config{}, items)
+
+	// (not cached, so they are fetched from the source)
+	clearItemsCache()
+	_itemsSource = fakeSource{items: map[a.Lang][]a.MarketItem{a.LangEnglish: items}}
+
+	summary := getSummary(testChatID, a.LangEnglish, collectionModePlayset, a.RarityAll)
+
+	for _, expected := range []string{
+		"Number of all items: 7",
+		"All 2 commons (4 cards): *$0.35*",    // Keefe the Bold (hero) x1 + Berserker's Call x3
+		"All 1 uncommons (3 cards): *$0.60*",  // Thunderhide Pack x3
+		"All 3 rares (5 cards): *$11.50*",     // Axe (hero) x1 + Bristleback (hero) x1 + Annihilation x3
+		"Price for full collection: *$12.45*", // (Emissary Pack of unknown type is not counted)
+		"Rarities of 1 items are unknown",
+	} {
+		if !strings.Contains(summary, expected) {
+			test.Errorf("expected summary containing '%s', got: %s", expected, summary)
+		}
+	}
+
+	if lastUpdatedOf(a.LangEnglish).IsZero() {
+		test.Errorf("expected fetched items to be cached")
+	}
+}
+
+// test rarity classification of recorded items
+func TestRarityOf(test *testing.T) {
+	expected := map[string]a.Rarity{
+		"Axe":              a.RarityRare,
+		"Bristleback":      a.RarityRare,
+		"Annihilation":     a.RarityRare,
+		"Thunderhide Pack": a.RarityUncommon,
+		"Keefe the Bold":   a.RarityCommon,
+		"Berserker's Call": a.RarityCommon,
+		"Emissary Pack":    a.RarityAll, // unknown type
+	}
+
+	items := loadFixtureItems(test)
+	if len(items) != len(expected) {
+		test.Fatalf("expected %d items in fixture, got %d", len(expected), len(items))
+	}
+
+	for _, item := range items {
+		if rarity := rarityOf(item, a.LangEnglish); rarity != expected[item.Name] {
+			test.Errorf("%s: expected rarity '%s', got '%s'", item.Name, expected[item.Name], rarity)
+		}
+	}
+}
+
+// test searching recorded items by name
+func TestSearchItemsByName(test *testing.T) {
+	setUpTest(test, config{}, loadFixtureItems(test))
+
+	for _, c := range []struct {
+		query    string
+		expected []string
+	}{
+		{"axe", []string{"Axe"}},
+		{"BRISTLE", []string{"Bristleback"}},
+		{"pack", []string{"Thunderhide Pack", "Emissary Pack"}},
+		{"bristlback", []string{"Bristleback"}}, // typo-tolerant fallback
+		{"nothing like this", []string{}},
+	} {
+		names := []string{}
+		for _, item := range searchItemsByName(c.query, a.LangEnglish) {
+			names = append(names, item.Name)
+		}
+
+		if strings.Join(names, ",") != strings.Join(c.expected, ",") {
+			test.Errorf("%s: expected %v, got %v", c.query, c.expected, names)
+		}
+	}
+}
+
+// test that all-time extremes of collection prices are recorded from English items only
+func TestCollectionPriceExtremesFromEnglish(test *testing.T) {
+	items := loadFixtureItems(test)
+	setUpTest(test, config{}, items)
+
+	// (not cached, so they are fetched from the source)
+	clearItemsCache()
+	_itemsSource = fakeSource{items: map[a.Lang][]a.MarketItem{
+		a.LangEnglish: items,
+		a.LangKorean: []a.MarketItem{
+			{Name: "도끼", HashName: "Axe", SellPrice: 100000, AssetDescription: a.AssetDescription{Type: "희귀 카드"}},
+		},
+	}}
+
+	getItems(a.LangKorean)
+	if lowest, highest := collectionPriceExtremes(); lowest != nil || highest != nil {
+		test.Errorf("expected no extremes from Korean items, got: %v, %v", lowest, highest)
+	}
+
+	getItems(a.LangEnglish)
+	if lowest, highest := collectionPriceExtremes(); lowest == nil || highest == nil || lowest.Price != 1245 || highest.Price != 1245 {
+		test.Errorf("expected extremes of $12.45 from English items, got: %v, %v", lowest, highest)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+// fuzzy.go
+//
+// typo-tolerant search of card names, with edit distances
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	// min length (in runes) of queries for fuzzy matching
+	minFuzzyQueryLength = 3
+
+	// max edit distance of fuzzy matches
+	maxFuzzyDistance = 3
+)
+
+// get edit (levenshtein) distance between given strings (in runes)
+func editDistance(s1, s2 string) int {
+	r1, r2 := []rune(s1), []rune(s2)
+
+	previous := make([]int, len(r2)+1)
+	current := make([]int, len(r2)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(r1); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(r2); j++ {
+			cost := 1
+			if r1[i-1] == r2[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(r2)]
+}
+
+// get the smaller one of given integers
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// get max edit distance allowed for given query (about a quarter of its length)
+func fuzzyThresholdOf(query string) int {
+	threshold := utf8.RuneCountInString(query) / 4
+	if threshold < 1 {
+		threshold = 1
+	} else if threshold > maxFuzzyDistance {
+		threshold = maxFuzzyDistance
+	}
+
+	return threshold
+}
+
+// search given items with typo-tolerant matching of given query, sorted by edit distance
+//
+// (compared with both the whole name and each word of it)
+func fuzzySearchItems(items []a.MarketItem, query string) []a.MarketItem {
+	query = strings.ToLower(query)
+	if utf8.RuneCountInString(query) < minFuzzyQueryLength {
+		return []a.MarketItem{}
+	}
+	threshold := fuzzyThresholdOf(query)
+
+	results := []a.MarketItem{}
+	distances := map[string]int{} // hash name => distance
+	for _, item := range items {
+		name := strings.ToLower(item.Name)
+
+		distance := editDistance(query, name)
+		for _, word := range strings.Fields(name) {
+			distance = minInt(distance, editDistance(query, word))
+		}
+
+		if distance <= threshold {
+			results = append(results, item)
+			distances[item.HashName] = distance
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return distances[results[i].HashName] < distances[results[j].HashName]
+	})
+
+	return results
+}
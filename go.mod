@@ -3,6 +3,8 @@ module github.com/meinside/telegram-bot-artifact
 go 1.12
 
 require (
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/meinside/steam-community-market-artifact v0.0.2
 	github.com/meinside/telegram-bot-go v0.0.11
+	golang.org/x/image v0.18.0
 )
@@ -0,0 +1,70 @@
+package main
+
+// hangul.go
+//
+// search of Korean card names with their initial consonants (초성)
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	hangulSyllableFirst = '가'
+	hangulSyllableLast  = '힣'
+
+	// number of syllables sharing the same initial consonant (21 medials * 28 finals)
+	hangulSyllablesPerInitial = 21 * 28
+)
+
+// initial consonants (초성) of hangul syllables, in the order of unicode
+var _hangulInitials = []rune{
+	'ㄱ', 'ㄲ', 'ㄴ', 'ㄷ', 'ㄸ', 'ㄹ', 'ㅁ', 'ㅂ', 'ㅃ', 'ㅅ',
+	'ㅆ', 'ㅇ', 'ㅈ', 'ㅉ', 'ㅊ', 'ㅋ', 'ㅌ', 'ㅍ', 'ㅎ',
+}
+
+// check if given rune is a hangul consonant jamo (ㄱ-ㅎ)
+func isHangulConsonant(r rune) bool {
+	return r >= 'ㄱ' && r <= 'ㅎ'
+}
+
+// check if given query consists of hangul consonants (and whitespaces) only
+func isHangulInitialsQuery(query string) bool {
+	hasConsonant := false
+
+	for _, r := range query {
+		if isHangulConsonant(r) {
+			hasConsonant = true
+		} else if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return hasConsonant
+}
+
+// get initial consonants of given text, with whitespaces removed
+//
+// (non-hangul characters are kept as they are)
+func hangulInitialsOf(txt string) string {
+	var b strings.Builder
+
+	for _, r := range txt {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		if r >= hangulSyllableFirst && r <= hangulSyllableLast {
+			b.WriteRune(_hangulInitials[(r-hangulSyllableFirst)/hangulSyllablesPerInitial])
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// check if initial consonants of given name contain given query of initial consonants
+func matchesHangulInitials(name, query string) bool {
+	return strings.Contains(hangulInitialsOf(name), hangulInitialsOf(query))
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+	t "github.com/meinside/telegram-bot-go"
+)
+
+// a message sent through `fakeSender`
+type sentMessage struct {
+	chatID t.ChatID
+	text   string
+}
+
+// fake sender which records sent messages instead of sending them
+type fakeSender struct {
+	sync.Mutex
+
+	messages    []sentMessage
+	numFiles    int // sent photos and documents
+	chatActions int
+	failures    []t.APIResponseBase // responses of the next sends, which fail
+}
+
+// pop the next failure (should be called while holding the lock)
+func (s *fakeSender) nextFailure() (t.APIResponseMessage, bool) {
+	if len(s.failures) > 0 {
+		failure := s.failures[0]
+		s.failures = s.failures[1:]
+
+		return t.APIResponseMessage{APIResponseBase: failure}, true
+	}
+
+	return t.APIResponseMessage{APIResponseBase: t.APIResponseBase{Ok: true}}, false
+}
+
+func (s *fakeSender) SendMessage(chatID t.ChatID, text string, options t.OptionsSendMessage) t.APIResponseMessage {
+	s.Lock()
+	defer s.Unlock()
+
+	res, failed := s.nextFailure()
+	if !failed {
+		s.messages = append(s.messages, sentMessage{chatID: chatID, text: text})
+	}
+
+	return res
+}
+
+func (s *fakeSender) SendPhoto(chatID t.ChatID, photoFile t.InputFile, options t.OptionsSendPhoto) t.APIResponseMessage {
+	return s.sendFile()
+}
+
+func (s *fakeSender) SendDocument(chatID t.ChatID, documentFile t.InputFile, options t.OptionsSendDocument) t.APIResponseMessage {
+	return s.sendFile()
+}
+
+// record a sent photo or document
+func (s *fakeSender) sendFile() t.APIResponseMessage {
+	s.Lock()
+	defer s.Unlock()
+
+	res, failed := s.nextFailure()
+	if !failed {
+		s.numFiles++
+	}
+
+	return res
+}
+
+func (s *fakeSender) SendChatAction(chatID t.ChatID, action t.ChatAction) t.APIResponseBool {
+	s.Lock()
+	defer s.Unlock()
+
+	s.chatActions++
+
+	return t.APIResponseBool{APIResponseBase: t.APIResponseBase{Ok: true}}
+}
+
+func (s *fakeSender) AnswerInlineQuery(inlineQueryID string, results []interface{}, options t.OptionsAnswerInlineQuery) t.APIResponseBool {
+	return t.APIResponseBool{APIResponseBase: t.APIResponseBase{Ok: true}}
+}
+
+func (s *fakeSender) AnswerCallbackQuery(callbackQueryID string, options t.OptionsAnswerCallbackQuery) t.APIResponseBool {
+	return t.APIResponseBool{APIResponseBase: t.APIResponseBase{Ok: true}}
+}
+
+func (s *fakeSender) EditMessageText(text string, options t.OptionsEditMessageText) t.APIResponseMessageOrBool {
+	return t.APIResponseMessageOrBool{APIResponseBase: t.APIResponseBase{Ok: true}}
+}
+
+// get the number of sent photos and documents
+func (s *fakeSender) numSentFiles() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.numFiles
+}
+
+// get the number of remaining failures
+func (s *fakeSender) numFailures() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.failures)
+}
+
+// get the number of sent chat actions
+func (s *fakeSender) numChatActions() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.chatActions
+}
+
+// get texts of sent messages
+func (s *fakeSender) texts() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	texts := []string{}
+	for _, message := range s.messages {
+		texts = append(texts, message.text)
+	}
+
+	return texts
+}
+
+// fake source of market items which never reaches Steam
+type fakeSource struct {
+	items map[a.Lang][]a.MarketItem
+}
+
+func (s fakeSource) FetchAll(rarity a.Rarity, language a.Lang, column a.SortColumn, direction a.SortDirection) ([]a.MarketItem, error) {
+	if items, exists := s.items[language]; exists {
+		return items, nil
+	}
+
+	return nil, errors.New("no items for language: " + string(language))
+}
+
+// recorded market items (commons, uncommons, rares, heroes, and an item of unknown type)
+const fixtureItemsPath = "testdata/items.json"
+
+// load recorded market items
+func loadFixtureItems(tb testing.TB) []a.MarketItem {
+	tb.Helper()
+
+	bytes, err := ioutil.ReadFile(fixtureItemsPath)
+	if err != nil {
+		tb.Fatalf("failed to read fixture: %s", err)
+	}
+
+	var items []a.MarketItem
+	if err := json.Unmarshal(bytes, &items); err != nil {
+		tb.Fatalf("failed to parse fixture: %s", err)
+	}
+
+	return items
+}
+
+// items for tests
+var _testItems = []a.MarketItem{
+	{Name: "Axe", HashName: "Axe", SellPrice: 150, SellPriceText: "$1.50", AssetDescription: a.AssetDescription{Type: "Rare Card"}},
+	{Name: "Thunderhide Pack", HashName: "Thunderhide Pack", SellPrice: 20, SellPriceText: "$0.20", AssetDescription: a.AssetDescription{Type: "Uncommon Card"}},
+	{Name: "Keefe the Bold", HashName: "Keefe the Bold", SellPrice: 5, SellPriceText: "$0.05", AssetDescription: a.AssetDescription{Type: "Common Card"}},
+}
+
+// set up global variables for tests with given config, and return a fake sender
+//
+// (items of all languages are cached, so that nothing is fetched from Steam)
+func setUpTest(tb testing.TB, c config, items []a.MarketItem) *fakeSender {
+	tb.Helper()
+
+	_confLock.Lock()
+	_conf = c
+	_confLock.Unlock()
+
+	_stateLock.Lock()
+	_state = state{}
+	_savedUpdateOffset = 0
+	_stateLock.Unlock()
+
+	_lock.Lock()
+	_items = map[a.Lang][]a.MarketItem{}
+	_itemsUpdated = map[a.Lang]time.Time{}
+	for _, language := range _languages {
+		_items[language] = items
+		_itemsUpdated[language] = time.Now()
+	}
+	_lock.Unlock()
+
+	_itemsSource = fakeSource{}
+	resetFetchSpacing()
+
+	return &fakeSender{}
+}
+
+// clear cached items, so that they are fetched from `_itemsSource`
+func clearItemsCache() {
+	_lock.Lock()
+	_items = map[a.Lang][]a.MarketItem{}
+	_itemsUpdated = map[a.Lang]time.Time{}
+	_lock.Unlock()
+}
+
+// let the next fetch start without waiting for the spacing between fetches
+func resetFetchSpacing() {
+	_fetchLock.Lock()
+	_lastFetched = time.Time{}
+	_fetchLock.Unlock()
+}
+
+// get a message update with given text
+func messageUpdate(chatID int64, from *t.User, txt string) t.Update {
+	return t.Update{
+		Message: &t.Message{
+			From: from,
+			Chat: &t.Chat{ID: chatID, Type: "private"},
+			Text: &txt,
+		},
+	}
+}
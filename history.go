@@ -0,0 +1,157 @@
+package main
+
+// history.go
+//
+// snapshots of market prices are recorded periodically, for trends and charts
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	// history filename
+	historyFilename = "history.json"
+
+	// default interval between history snapshots
+	defaultHistorySnapshotMinutes = 60
+
+	// default retention of history entries
+	defaultHistoryRetentionDays = 30
+)
+
+// price history entry
+type historyEntry struct {
+	Time       time.Time        `json:"time"`
+	Collection int              `json:"collection"` // price of full collection (in cents)
+	Rarities   map[a.Rarity]int `json:"rarities"`   // rarity => price of all needed cards (in cents)
+	Cards      map[string]int   `json:"cards"`      // hash name => sell price (in cents)
+}
+
+var _history []historyEntry
+var _historyLock sync.Mutex
+
+// load price history (empty on failure)
+func loadHistory() []historyEntry {
+	var history []historyEntry
+	if err := loadJSONFile(historyFilename, &history); err != nil {
+		if !os.IsNotExist(err) {
+			logWarnf("Failed to load history: %s", err)
+		}
+
+		return []historyEntry{}
+	}
+
+	return history
+}
+
+// get retention of price history
+func historyRetention() time.Duration {
+	days := conf().HistoryRetentionDays
+	if days <= 0 {
+		days = defaultHistoryRetentionDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// get interval between history snapshots
+func historySnapshotInterval() time.Duration {
+	minutes := conf().HistorySnapshotMinutes
+	if minutes <= 0 {
+		minutes = defaultHistorySnapshotMinutes
+	}
+
+	return time.Duration(minutes) * time.Minute
+}
+
+// record snapshots of prices in price history periodically, regardless of incoming requests
+//
+// (cached items are reused, so items are fetched only when the cache is outdated)
+func runHistorySnapshots() {
+	interval := historySnapshotInterval()
+
+	for {
+		if items := getItems(a.LangEnglish); len(items) > 0 {
+			recordHistory(items, a.LangEnglish)
+		} else {
+			logInfof("Skipping history snapshot: no items")
+		}
+
+		time.Sleep(interval)
+
+		// interval can be changed by reloading config
+		interval = historySnapshotInterval()
+	}
+}
+
+// get price history entries recorded since given time
+func historySince(since time.Time) []historyEntry {
+	_historyLock.Lock()
+	defer _historyLock.Unlock()
+
+	entries := []historyEntry{}
+	for _, entry := range _history {
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// get up to `n` most recent prices (in cents) of a card with given hash name in price history, oldest first
+func recentCardPrices(hashName string, n int) []int {
+	_historyLock.Lock()
+	defer _historyLock.Unlock()
+
+	prices := []int{}
+	for i := len(_history) - 1; i >= 0 && len(prices) < n; i-- {
+		if price, exists := _history[i].Cards[hashName]; exists && price > 0 {
+			prices = append([]int{price}, prices...)
+		}
+	}
+
+	return prices
+}
+
+// record prices of given items in price history
+func recordHistory(items []a.MarketItem, language a.Lang) {
+	_historyLock.Lock()
+	defer _historyLock.Unlock()
+
+	now := time.Now()
+
+	totals := totalsOf(items, language, collectionModePlayset)
+	entry := historyEntry{
+		Time:       now,
+		Collection: collectionPriceOf(totals),
+		Rarities:   map[a.Rarity]int{},
+		Cards:      map[string]int{},
+	}
+	for rarity, total := range totals {
+		entry.Rarities[rarity] = total.price
+	}
+	for _, item := range items {
+		entry.Cards[item.HashName] = item.SellPrice
+	}
+	_history = append(_history, entry)
+
+	// prune outdated entries
+	pruned := 0
+	for pruned < len(_history) && now.Sub(_history[pruned].Time) > historyRetention() {
+		pruned++
+	}
+	if pruned > 0 {
+		_history = _history[pruned:]
+
+		logInfof("Pruned %d outdated history entries", pruned)
+	}
+
+	if err := saveJSONFile(historyFilename, _history); err != nil {
+		logErrorf("Failed to save history: %s", err)
+	}
+}
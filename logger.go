@@ -0,0 +1,66 @@
+package main
+
+// logger.go
+//
+// logs of the bot are leveled, and ones below `log_level` in config are discarded
+
+import (
+	"log"
+	"strings"
+)
+
+// log levels
+type logLevel int
+
+const (
+	logLevelDebug logLevel = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+// names of log levels in config
+var _logLevelNames = map[string]logLevel{
+	"debug": logLevelDebug,
+	"info":  logLevelInfo,
+	"warn":  logLevelWarn,
+	"error": logLevelError,
+}
+
+// get log level from config (default: info)
+func currentLogLevel() logLevel {
+	if level, exists := _logLevelNames[strings.ToLower(conf().LogLevel)]; exists {
+		return level
+	}
+
+	return logLevelInfo
+}
+
+// log given message with prefix, if given level is not below the one in config
+func logf(level logLevel, prefix, format string, v ...interface{}) {
+	if level < currentLogLevel() {
+		return
+	}
+
+	log.Printf(prefix+format, v...)
+}
+
+// log debug message (eg. for tracing inline queries)
+func logDebugf(format string, v ...interface{}) {
+	logf(logLevelDebug, "[DEBUG] ", format, v...)
+}
+
+// log informative message
+func logInfof(format string, v ...interface{}) {
+	logf(logLevelInfo, "[INFO] ", format, v...)
+}
+
+// log warning message (eg. for recoverable failures)
+func logWarnf(format string, v ...interface{}) {
+	logf(logLevelWarn, "[WARN] ", format, v...)
+}
+
+// log error message (eg. for failures of sending messages)
+func logErrorf(format string, v ...interface{}) {
+	logf(logLevelError, "[ERROR] ", format, v...)
+}
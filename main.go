@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	a "github.com/meinside/steam-community-market-artifact"
 	t "github.com/meinside/telegram-bot-go"
@@ -19,13 +33,55 @@ const (
 	// config filename
 	confFilename = "config.json"
 
-	// cache ttl
-	cacheMinutes = 5
+	// default cache ttl
+	defaultCacheMinutes = 5
+
+	// interval of repeated chat actions (they expire in about 5 seconds)
+	chatActionIntervalSeconds = 4
 
 	// commands
-	commandStart     = "/start"
-	commandSummarize = "/summarize"
-	commandHelp      = "/help"
+	commandStart         = "/start"
+	commandSummarize     = "/summarize"
+	commandAffordable    = "/affordable"
+	commandNearby        = "/nearby"
+	commandSetCurrency   = "/setcurrency"
+	commandNotifyNew     = "/notifynew"
+	commandSubscribe     = "/subscribe"
+	commandUnsubscribe   = "/unsubscribe"
+	commandExtremes      = "/extremes"
+	commandTrendChart    = "/trendchart"
+	commandChanges       = "/changes"
+	commandCompletion    = "/completioncost"
+	commandTop           = "/top"
+	commandCheapest      = "/cheapest"
+	commandCard          = "/card"
+	commandOwn           = "/own"
+	commandWatch         = "/watch"
+	commandWatchlist     = "/watchlist"
+	commandUnwatch       = "/unwatch"
+	commandMeta          = "/meta"
+	commandHeroSummary   = "/herosummary"
+	commandRemaining     = "/remaining"
+	commandDeckCost      = "/deckcost"
+	commandConvert       = "/convert"
+	commandTax           = "/tax"
+	commandExport        = "/export"
+	commandDefault       = "/default"
+	commandLang          = "/lang"
+	commandMySettings    = "/mysettings"
+	commandAssumptions   = "/assumptions"
+	commandResetSettings = "/resetsettings"
+	commandHelp          = "/help"
+	commandCommands      = "/commands"
+
+	// admin commands
+	commandReport       = "/report"
+	commandStats        = "/stats"
+	commandResetStats   = "/resetstats"
+	commandReloadConfig = "/reloadconfig"
+	commandReload       = "/reload"
+	commandRaw          = "/raw"
+	commandSuspectHero  = "/suspecthero"
 
 	// messages
 	messageUnknownCommand = "Unknown command"
@@ -35,8 +91,41 @@ This is a Telegram bot which fetches information of *Artifact* from _Steam Commu
 
 Supported commands are as following:
 
-%s: Summarize current market information.
-%s: Show this help message.
+%s [rarity]: Summarize current market information. (only of given rarity, if any)
+  (append _singles_ for one of each card, or _playset_ for full playsets)
+%s: Show all-time lowest and highest prices of full collection.
+%s [window]: Show a chart of full collection's price over given window. (eg. _24h_, _7d_)
+%s: Show cards whose type or rarity changed recently.
+%s [n]: List n cards which cost the most to complete the collection.
+%s [n]: List n most expensive cards. (default: 5)
+%s [n]: List n least expensive cards. (default: 5)
+%s: Show an overview of heroes' prices.
+%s: Summarize the price of all hero cards.
+  (append _singles_ for one of each card, or _playset_ for full playsets)
+%s [card name]: Show details of a card with its recent price trend.
+%s [add|remove] [card name] [count]: Manage cards you own. (without arguments, list them)
+%s: Calculate the cost to complete the collection, excluding owned cards.
+  (append _singles_ for one of each card, or _playset_ for full playsets)
+%s [card names]: Calculate the cost of a deck. (separated by commas or newlines, with optional counts like _3x_)
+%s [card name] [price]: Get alerted once when the price of a card reaches given price. (without arguments, list them)
+%s: List watched prices with current prices, flagging the ones already reached.
+%s [card name]: Stop watching the price of a card.
+%s [rarity] [max price]: List cards of given rarity priced at or below given price.
+%s [price] [tolerance]: List cards priced close to given price. (default tolerance: 10%%)
+%s [USD,KRW,...]: Set currencies for displaying prices.
+%s: Get notified of new card types in the market. (append _off_ to stop)
+%s: Get the summary of the market every day.
+%s: Stop getting the daily summary.
+%s [amount]: Calculate tax/fee for an amount of money in USD.
+%s [amount] [from] [to]: Convert an amount of money between currencies.
+%s [json|jsonl|csv]: Export current market data as a file.
+%s [search|summarize|none]: Set what to do with texts which are not commands.
+%s [en|ko|auto]: Set the language of messages. (_auto_ for the language of your Telegram app)
+%s: Show your settings.
+%s: Show assumptions used for calculating prices.
+%s: Reset your settings.
+%s: Show this help message. (append _image_ for an image)
+%s: List all commands.
 
 You can search for card info in chats with:
 
@@ -50,8 +139,41 @@ _스팀 커뮤니티 장터_에서 *Artifact* 정보를 가져오는 Telegram Bo
 
 지원되는 명령어는 다음과 같습니다:
 
-%s: 현재 장터 정보를 요약합니다.
-%s: 이 도움말을 표시합니다.
+%s [등급]: 현재 장터 정보를 요약합니다. (등급을 주면 해당 등급만)
+  (종류별 1장 기준은 _singles_, 플레이세트 기준은 _playset_ 을 덧붙입니다)
+%s: 풀 컬렉션 수집 비용의 역대 최저가와 최고가를 표시합니다.
+%s [기간]: 주어진 기간 동안의 풀 컬렉션 수집 비용 차트를 표시합니다. (예: _24h_, _7d_)
+%s: 최근 종류나 등급이 바뀐 카드를 표시합니다.
+%s [n]: 컬렉션 완성 비용이 가장 큰 카드 n개를 표시합니다.
+%s [n]: 가장 비싼 카드 n개를 표시합니다. (기본: 5)
+%s [n]: 가장 저렴한 카드 n개를 표시합니다. (기본: 5)
+%s: 영웅 카드의 가격 개요를 표시합니다.
+%s: 모든 영웅 카드의 가격을 요약합니다.
+  (종류별 1장 기준은 _singles_, 플레이세트 기준은 _playset_ 을 덧붙입니다)
+%s [카드 이름]: 카드의 상세 정보와 최근 가격 추세를 표시합니다.
+%s [add|remove] [카드 이름] [수량]: 보유한 카드를 관리합니다. (인자가 없으면 목록을 표시)
+%s: 보유한 카드를 제외하고 컬렉션 완성 비용을 계산합니다.
+  (종류별 1장 기준은 _singles_, 플레이세트 기준은 _playset_ 을 덧붙입니다)
+%s [카드 이름들]: 덱의 비용을 계산합니다. (쉼표나 줄바꿈으로 구분, _3x_ 처럼 수량 지정 가능)
+%s [카드 이름] [가격]: 카드의 가격이 주어진 가격에 도달하면 한 번 알림을 받습니다. (인자가 없으면 목록을 표시)
+%s: 가격 알림 목록을 현재 가격과 함께 표시하고, 이미 도달한 것을 표시합니다.
+%s [카드 이름]: 카드의 가격 알림을 중지합니다.
+%s [등급] [최대 가격]: 주어진 등급에서 주어진 가격 이하의 카드 목록을 표시합니다.
+%s [가격] [허용 오차]: 주어진 가격에 가까운 카드 목록을 표시합니다. (기본 허용 오차: 10%%)
+%s [USD,KRW,...]: 가격을 표시할 통화를 설정합니다.
+%s: 장터에 새로운 카드 종류가 등장하면 알림을 받습니다. (중지하려면 _off_ 를 덧붙입니다)
+%s: 매일 장터 정보 요약을 받습니다.
+%s: 매일 받는 장터 정보 요약을 중지합니다.
+%s [금액]: USD 금액에 대한 세금/수수료를 계산합니다.
+%s [금액] [원래 통화] [바꿀 통화]: 금액을 다른 통화로 환산합니다.
+%s [json|jsonl|csv]: 현재 장터 정보를 파일로 내보냅니다.
+%s [search|summarize|none]: 명령어가 아닌 텍스트를 받았을 때 할 일을 설정합니다.
+%s [en|ko|auto]: 메시지의 언어를 설정합니다. (Telegram 앱의 언어를 따르려면 _auto_)
+%s: 설정을 표시합니다.
+%s: 가격 계산에 사용되는 전제 조건을 표시합니다.
+%s: 설정을 초기화합니다.
+%s: 이 도움말을 표시합니다. (_image_를 붙이면 이미지로 표시)
+%s: 모든 명령어를 표시합니다.
 
 대화창에서
 
@@ -61,63 +183,649 @@ _스팀 커뮤니티 장터_에서 *Artifact* 정보를 가져오는 Telegram Bo
 
 - 소스 코드: https://github.com/meinside/telegram-bot-artifact
 `
-	messageSummaryEng = `*Summary:*
+	messageSummaryEng = `*Summary (%s):*
 
 Number of all items: %d
-All %d commons (%d cards): *$%.2f*
-All %d uncommons (%d cards): *$%.2f*
-All %d rares (%d cards): *$%.2f*
+%sAll %d commons (%d cards): *%s*
+%sAll %d uncommons (%d cards): *%s*
+%sAll %d rares (%d cards): *%s*
 ----
-Price for full collection: *$%.2f* (+ tax/fee $%.2f = *$%.2f*)
+Price for full collection: *%s* (+ tax/fee %s = *%s*)%s%s
 
 _last update: %s_
 `
-	messageSummaryKor = `*요약:*
+	messageSummaryKor = `*요약 (%s):*
 
 모든 항목: %d종
-모든 일반 카드 %d종 (%d 장): *$%.2f*
-모든 고급 카드 %d종 (%d 장): *$%.2f*
-모든 희귀 카드 %d종 (%d 장): *$%.2f*
+%s모든 일반 카드 %d종 (%d 장): *%s*
+%s모든 고급 카드 %d종 (%d 장): *%s*
+%s모든 희귀 카드 %d종 (%d 장): *%s*
+----
+풀 컬렉션 수집 비용: *%s* (+ 세금/수수료 %s = *%s*)%s%s
+
+_마지막 갱신: %s_
+`
+
+	messageMetaEng = `*Heroes:*
+
+Tracked heroes: %d (%d in the market)
+Cheapest: %s (*%s*)
+Most expensive: %s (*%s*)
+Average price: *%s*%s`
+	messageMetaKor = `*영웅:*
+
+등록된 영웅: %d (장터에 %d)
+최저가: %s (*%s*)
+최고가: %s (*%s*)
+평균 가격: *%s*%s`
+	messageHeroesEng         = "Heroes"
+	messageHeroesKor         = "영웅"
+	messageMetaUnresolvedEng = "\n\n_Not in the market:_ %s"
+	messageMetaUnresolvedKor = "\n\n_장터에 없음:_ %s"
+	messageMetaNoneEng       = "No hero card in the market."
+	messageMetaNoneKor       = "장터에 영웅 카드가 없습니다."
+	messageRemainingEng      = `*Remaining cost to complete the collection (%s):*
+
+Owned cards: %d
+%s%d commons (%d cards): *%s*
+%s%d uncommons (%d cards): *%s*
+%s%d rares (%d cards): *%s*
+----
+Remaining price: *%s* (+ tax/fee %s = *%s*)`
+	messageRemainingKor = `*남은 컬렉션 완성 비용 (%s):*
+
+보유한 카드: %d 장
+%s일반 카드 %d종 (%d 장): *%s*
+%s고급 카드 %d종 (%d 장): *%s*
+%s희귀 카드 %d종 (%d 장): *%s*
 ----
-풀 컬렉션 수집 비용: *$%.2f* (+ 세금/수수료 $%.2f = *$%.2f*)
+남은 비용: *%s* (+ 세금/수수료 %s = *%s*)`
+	messageOwnedEng      = "*Owned cards:*\n\n%s"
+	messageOwnedKor      = "*보유한 카드:*\n\n%s"
+	messageOwnedNoneEng  = "You don't own any card yet.\n(add one with: %s add [card name] [count])"
+	messageOwnedNoneKor  = "보유한 카드가 없습니다.\n(추가하려면: %s add [카드 이름] [수량])"
+	messageOwnedCountEng = "You own %d of %s now."
+	messageOwnedCountKor = "이제 %s 카드를 %d 장 보유하고 있습니다."
+	messageOwnUsageEng   = "Usage:\n%s add [card name] [count]\n%s remove [card name] [count]\n%s (list owned cards)"
+	messageOwnUsageKor   = "사용법:\n%s add [카드 이름] [수량]\n%s remove [카드 이름] [수량]\n%s (보유한 카드 목록)"
+
+	messageAffordableEng      = "*Affordable %s (up to $%.2f):*\n\n%s"
+	messageAffordableKor      = "*%s ($%.2f 이하):*\n\n%s"
+	messageAffordableNoneEng  = "No %s priced at or below $%.2f."
+	messageAffordableNoneKor  = "$%.2f 이하의 %s가 없습니다."
+	messageAffordableUsageEng = "Usage: %s [common|uncommon|rare] [max price in USD]\n(e.g. %s rare 3)"
+	messageAffordableUsageKor = "사용법: %s [일반|고급|희귀] [최대 가격(USD)]\n(예: %s 희귀 3)"
+	messageListClampedEng     = "\n_(only up to %d items can be listed)_"
+	messageListClampedKor     = "\n_(최대 %d개까지만 표시할 수 있습니다)_"
+	messageCompletionCostEng  = "*Top %d cards by cost to complete:*\n\n%s%s"
+	messageCompletionCostKor  = "*컬렉션 완성 비용 상위 %d개 카드:*\n\n%s%s"
+	messageCompletionUsageEng = "Usage: %s [number of cards]\n(e.g. %s 10)"
+	messageCompletionUsageKor = "사용법: %s [카드 수]\n(예: %s 10)"
+	messageTopEng             = "*Top %d most expensive cards:*\n\n%s"
+	messageTopKor             = "*가장 비싼 카드 %d개:*\n\n%s"
+	messageCheapestEng        = "*Top %d least expensive cards:*\n\n%s"
+	messageCheapestKor        = "*가장 저렴한 카드 %d개:*\n\n%s"
+	messageRankedUsageEng     = "Usage: %s [number of cards]\n(e.g. %s 10)"
+	messageRankedUsageKor     = "사용법: %s [카드 수]\n(예: %s 10)"
+	messageAmbiguousEng       = "%d cards match '%s', please be more specific:\n\n%s"
+	messageAmbiguousKor       = "'%s'에 해당하는 카드가 %d개 있습니다. 더 정확하게 입력해 주세요:\n\n%s"
+	messageCardUsageEng       = "Usage: %s [card name]\n(e.g. %s Axe)"
+	messageCardUsageKor       = "사용법: %s [카드 이름]\n(예: %s 도끼)"
+	messageCardTrendEng       = "Trend: %s"
+	messageCardTrendKor       = "추세: %s"
+	messageNearbyEng          = "*Cards priced within $%.2f of $%.2f:*\n\n%s"
+	messageNearbyKor          = "*$%.2f ± $%.2f 가격대의 카드:*\n\n%s"
+	messageNearbyNoneEng      = "No card priced within $%.2f of $%.2f."
+	messageNearbyNoneKor      = "$%.2f ± $%.2f 가격대의 카드가 없습니다."
+	messageNearbyUsageEng     = "Usage: %s [price in USD] [tolerance in USD]\n(e.g. %s 1.50 0.10)"
+	messageNearbyUsageKor     = "사용법: %s [USD 가격] [USD 허용 오차]\n(예: %s 1.50 0.10)"
+	messageListMoreEng        = "... and %d more"
+	messageListMoreKor        = "... 외 %d개"
+
+	messageSetCurrencyEng      = "Currencies were set to: *%s*"
+	messageSetCurrencyKor      = "통화가 설정되었습니다: *%s*"
+	messageSetCurrencyErrorEng = "Failed to set currencies: %s"
+	messageSetCurrencyErrorKor = "통화 설정에 실패했습니다: %s"
+	messageSetCurrencyUsageEng = "Usage: %s [comma-separated currency codes, up to %d]\n(e.g. %s USD,KRW)"
+	messageSetCurrencyUsageKor = "사용법: %s [쉼표로 구분된 통화 코드, 최대 %d개]\n(예: %s USD,KRW)"
+
+	messageRaritySummaryEng = `*%s%s (%s):*
+
+Number of items: %d (%d cards)
+Price: *%s* (+ tax/fee %s = *%s*)
+
+_last update: %s_
+`
+	messageRaritySummaryKor = `*%s%s (%s):*
+
+항목: %d종 (%d 장)
+비용: *%s* (+ 세금/수수료 %s = *%s*)
 
 _마지막 갱신: %s_
 `
 
+	messageExtremesEng = `*All-time prices of full collection:*
+
+Lowest: *%s* (_%s_)
+Highest: *%s* (_%s_)`
+	messageExtremesKor = `*역대 풀 컬렉션 수집 비용:*
+
+최저: *%s* (_%s_)
+최고: *%s* (_%s_)`
+	messageExtremesNoneEng = "No price of full collection was recorded yet."
+	messageExtremesNoneKor = "아직 기록된 풀 컬렉션 수집 비용이 없습니다."
+
+	messageChangesEng     = "*Recent changes of cards' types:*\n\n%s"
+	messageChangesKor     = "*최근 카드 종류 변경 내역:*\n\n%s"
+	messageChangesNoneEng = "No card has changed its type recently."
+	messageChangesNoneKor = "최근 종류가 바뀐 카드가 없습니다."
+
+	messageNotifyNewOnEng   = "You will be notified of new card types in the market.\n(send `%s off` to stop)"
+	messageNotifyNewOnKor   = "장터에 새로운 카드 종류가 등장하면 알려드립니다.\n(중지하려면 `%s off` 를 보내세요)"
+	messageNotifyNewOffEng  = "You will no longer be notified of new card types."
+	messageNotifyNewOffKor  = "더 이상 새로운 카드 종류를 알려드리지 않습니다."
+	messageSubscribedEng    = "You will get the summary of the market every day at %02d:00 (%s).\n(send %s to stop)"
+	messageSubscribedKor    = "매일 %02d:00 (%s)에 장터 정보 요약을 보내드립니다.\n(중지하려면 %s 를 보내세요)"
+	messageUnsubscribedEng  = "You will no longer get the daily summary."
+	messageUnsubscribedKor  = "더 이상 매일 장터 정보 요약을 보내드리지 않습니다."
+	messageNotSubscribedEng = "You are not subscribed to the daily summary."
+	messageNotSubscribedKor = "매일 장터 정보 요약을 받고 있지 않습니다."
+
+	messageTaxEng = `Price: *%s*
+Tax/fee: %s
+Total: *%s*`
+	messageTaxKor = `가격: *%s*
+세금/수수료: %s
+합계: *%s*`
+	messageTaxUsageEng        = "Usage: %s [amount in USD]\n(e.g. %s 12.50)"
+	messageTaxUsageKor        = "사용법: %s [USD 금액]\n(예: %s 12.50)"
+	messageConvertEng         = "%s = *%s*"
+	messageConvertKor         = "%s = *%s*"
+	messageConvertErrorEng    = "Failed to convert: %s"
+	messageConvertErrorKor    = "환산에 실패했습니다: %s"
+	messageNoExchangeRatesEng = "Exchange rates are not available now. Please try again later."
+	messageNoExchangeRatesKor = "지금은 환율 정보를 가져올 수 없습니다. 잠시 후 다시 시도해 주세요."
+	messageConvertUsageEng    = "Usage: %s [amount] [from currency] [to currency]\n(e.g. %s 12.50 USD KRW)"
+	messageConvertUsageKor    = "사용법: %s [금액] [원래 통화] [바꿀 통화]\n(예: %s 12.50 USD KRW)"
+
+	messageAssumptionsEng = `*Assumptions:*
+
+Tax/fee: %.4g%% of prices
+Cards per item in full playsets: %d (heroes: %d)
+Cards per item in one of each: 1
+Cards of unknown rarity: not counted
+Basic heroes (given for free): %s
+Prices are cached for: %d minute(s)`
+	messageAssumptionsKor = `*전제 조건:*
+
+세금/수수료: 가격의 %.4g%%
+플레이세트의 항목당 카드 수: %d 장 (영웅: %d 장)
+종류별 1장의 항목당 카드 수: 1 장
+등급을 알 수 없는 카드: 제외
+기본 영웅 (무료로 지급): %s
+가격 캐시 시간: %d 분`
+	messageCountedEng             = "counted"
+	messageCountedKor             = "포함"
+	messageExcludedEng            = "not counted"
+	messageExcludedKor            = "제외"
+	messageTrendChartTitleEng     = "Price of full collection (last %s)"
+	messageTrendChartTitleKor     = "풀 컬렉션 수집 비용 (최근 %s)"
+	messageTrendChartXAxisEng     = "Time (UTC)"
+	messageTrendChartXAxisKor     = "시간 (UTC)"
+	messageTrendChartYAxisEng     = "Price (USD)"
+	messageTrendChartYAxisKor     = "가격 (USD)"
+	messageTrendChartUsageEng     = "Usage: %s [window]\n(e.g. %s 24h, %s 7d)"
+	messageTrendChartUsageKor     = "사용법: %s [기간]\n(예: %s 24h, %s 7d)"
+	messageTrendChartNoHistoryEng = "Not enough price history for the last %s yet."
+	messageTrendChartNoHistoryKor = "최근 %s 동안의 가격 기록이 아직 충분하지 않습니다."
+	messageTrendChartErrorEng     = "Failed to draw a chart: %s"
+	messageTrendChartErrorKor     = "차트를 그리지 못했습니다: %s"
+	messageCommandsEng            = "*Commands:*\n\n%s"
+	messageCommandsKor            = "*명령어:*\n\n%s"
+	messageMySettingsEng          = `*Your settings:*
+
+Currencies: %s
+Texts which are not commands: %s
+Language: %s
+Notifications of new card types: %s
+Daily summary: %s
+Owned cards: %d
+Watched prices: %d`
+	messageMySettingsKor = `*설정:*
+
+통화: %s
+명령어가 아닌 텍스트: %s
+언어: %s
+새로운 카드 종류 알림: %s
+매일 요약: %s
+보유한 카드: %d장
+가격 알림: %d개`
+	messageExportUsageEng     = "Usage: %s [json|jsonl|csv]"
+	messageExportUsageKor     = "사용법: %s [json|jsonl|csv]"
+	messageExportErrorEng     = "Failed to export market data: %s"
+	messageExportErrorKor     = "장터 정보를 내보내지 못했습니다: %s"
+	messageSetDefaultEng      = "Texts which are not commands will be handled with: *%s*"
+	messageSetDefaultKor      = "명령어가 아닌 텍스트는 다음으로 처리됩니다: *%s*"
+	messageSetDefaultUsageEng = "Usage: %s [search|summarize|none]"
+	messageSetDefaultUsageKor = "사용법: %s [search|summarize|none]"
+	messageSetLangEng         = "Messages will be in: *%s*"
+	messageSetLangKor         = "메시지가 다음 언어로 표시됩니다: *%s*"
+	messageSetLangAutoEng     = "Messages will be in the language of your Telegram app."
+	messageSetLangAutoKor     = "메시지가 Telegram 앱의 언어로 표시됩니다."
+	messageSetLangUsageEng    = "Usage: %s [%s|auto]"
+	messageSetLangUsageKor    = "사용법: %s [%s|auto]"
+	messageSearchResultsEng   = "*Cards matching* '%s':\n\n%s"
+	messageSearchResultsKor   = "'%s' *검색 결과:*\n\n%s"
+	messageSearchNoResultsEng = "No card matching '%s'."
+	messageSearchNoResultsKor = "'%s'와 일치하는 카드가 없습니다."
+	messageDefaultCurrencyEng = "%s (default)"
+	messageDefaultCurrencyKor = "%s (기본값)"
+	messageOnEng              = "on"
+	messageOnKor              = "켜짐"
+	messageOffEng             = "off"
+	messageOffKor             = "꺼짐"
+	messageResetSettingsEng   = "Your settings were reset."
+	messageResetSettingsKor   = "설정이 초기화되었습니다."
+
+	messageUnclassifiedEng = "\n⚠ _Rarities of %d items are unknown in this language, so this summary is incomplete._\n"
+	messageUnclassifiedKor = "\n⚠ _이 언어에서 %d종의 등급을 알 수 없어 요약이 불완전합니다._\n"
+
+	messageStaleEng = "\n⚠ _(data may be stale)_\n"
+	messageStaleKor = "\n⚠ _(오래된 정보일 수 있습니다)_\n"
+
+	messageBasicCardsExcludedEng = "\n_(%d basic cards are excluded)_"
+	messageBasicCardsExcludedKor = "\n_(기본 카드 %d종은 제외되었습니다)_"
+
+	messageChangedSinceLastCheckEng = "\nChanged by *%s%s* since your last check (_%s_)"
+	messageChangedSinceLastCheckKor = "\n마지막 확인(_%s_) 이후 *%s%s* 변동"
+
+	messageCooldownEng = "Please wait %d second(s) before using %s again."
+	messageCooldownKor = "%d초 후에 %s 명령을 다시 사용할 수 있습니다."
+
+	messageNeverUpdatedEng = "never"
+	messageNeverUpdatedKor = "없음"
+	messageSecondsAgoEng   = "%d second(s) ago"
+	messageSecondsAgoKor   = "%d초 전"
+	messageMinutesAgoEng   = "%d minute(s) ago"
+	messageMinutesAgoKor   = "%d분 전"
+	messageHoursAgoEng     = "%d hour(s) ago"
+	messageHoursAgoKor     = "%d시간 전"
+	messageDaysAgoEng      = "%d day(s) ago"
+	messageDaysAgoKor      = "%d일 전"
+
+	messageReport = `*Report:*
+
+%s`
+	messageReportLanguage = `*%s*
+Number of cached items: %d
+Items without price: %d
+Items without icon: %d
+Items of unknown rarity: %d
+Cache age: %s
+`
+	messageReportNeverUpdated = "never updated"
+
+	messageStats = `*Stats:*
+
+Uptime: %s (since %s)
+Cache TTL: %s
+
+%s
+
+Since %s:
+Messages: %d
+Inline queries: %d
+Cache hits: %d
+Cache misses: %d`
+	messageStatsLanguage = "*%s*: %d item(s), last updated: %s"
+
+	messageReloadConfig             = "*Config was reloaded.*"
+	messageReloadConfigNeedsRestart = "*Config was reloaded*, but changes of following fields need a restart: %s"
+	messageReloadConfigFailed       = "Failed to reload config: %s"
+
+	messageReloadItems            = "*Items were reloaded:*\n\n%s"
+	messageReloadItemsLanguage    = "*%s*: %d item(s), updated: %s"
+	messageReloadItemsFailed      = "*%s*: failed to reload"
+	messageReloadItemsUnknownLang = "Unknown language code: '%s'"
+
+	messageResetStats = `*Statistics were reset.*
+
+Before reset (since %s):
+Messages: %d
+Inline queries: %d
+Cache hits: %d
+Cache misses: %d`
+
+	messageRaw          = "%s (%d match(es))\n```\n%s\n```"
+	messageRawTruncated = "\n... (truncated)"
+	messageRawUsage     = "Usage: %s [card name]"
+	messageRawNotFound  = "No card matching '%s'."
+	messageRawError     = "Failed to marshal card: %s"
+
+	messageSuspectHeroes = `*Cards which look like heroes, but are not in the hero list (%s):*
+
+%s`
+	messageSuspectHeroesNone = "No suspicious card in the market (%s)."
+
 	timestampFormat = `2006-01-02 (Mon) 15:04:05 MST`
 )
 
 const (
 	maxNumCardsPerDeck     = 3
 	maxNumHeroCardsPerDeck = 1
+
+	// suffix of basic heroes (which are given for free) in lists of heroes
+	basicHeroSuffix = " (basic)"
+
+	// default rate of tax/fee of the market
+	defaultTaxRate = 0.15
+
+	// max length (in runes) of search queries
+	maxQueryLength = 50
+
+	// max length of a message
+	maxMessageLength = 4096
+
+	// max length of a caption of photos
+	maxCaptionLength = 1024
+
+	// max length of raw json of a card (should be shorter than `maxMessageLength`)
+	maxRawLength = 3500
+
+	// ratio of unclassified items over which summaries are warned to be incomplete
+	maxUnclassifiedRatio = 0.1
+
+	// default number of items in lists requested without a count
+	defaultNumListedItems = 10
+
+	// max number of results in an answer of inline query (limited by Telegram)
+	maxNumInlineResults = 50
+
+	// default max number of items in a listed message
+	defaultMaxNumListedItems = 30
+
+	// default number of items listed by `commandTop` and `commandCheapest`
+	defaultNumTopItems = 5
+)
+
+// default action for texts which are not commands
+type defaultAction string
+
+const (
+	defaultActionNone      defaultAction = "none"      // reply as unknown command
+	defaultActionSearch    defaultAction = "search"    // search cards with the text
+	defaultActionSummarize defaultAction = "summarize" // summarize the market
+)
+
+// collection mode (number of cards assumed for each item)
+type collectionMode string
+
+const (
+	collectionModePlayset collectionMode = "playset" // max number of cards per deck
+	collectionModeSingles collectionMode = "singles" // one of each card
 )
 
 // config struct
 type config struct {
-	Token                  string `json:"token"`                    // Telegram bot token
-	MonitorIntervalSeconds int    `json:"monitor_interval_seconds"` // polling interval seconds
-	Verbose                bool   `json:"verbose"`                  // show verbose logs or not
+	Token                     string  `json:"token"`                       // Telegram bot token
+	MonitorIntervalSeconds    int     `json:"monitor_interval_seconds"`    // polling interval seconds
+	Verbose                   bool    `json:"verbose"`                     // show verbose logs or not
+	WatchdogTimeoutSeconds    int     `json:"watchdog_timeout_seconds"`    // seconds without successful polls of updates before restarting monitoring (0 = disabled)
+	AdminChatIDs              []int64 `json:"admin_chat_ids,omitempty"`    // chat ids of admins
+	ExchangeRatesURL          string  `json:"exchange_rates_url"`          // endpoint of USD-based exchange rates (`{"rates": {"KRW": ...}}`)
+	ProxyURL                  string  `json:"proxy_url,omitempty"`         // proxy for outbound requests (eg. "http://host:port", "socks5://host:port")
+	ShowWebPagePreviews       bool    `json:"show_web_page_previews"`      // show previews of links in messages or not
+	HistoryRetentionDays      int     `json:"history_retention_days"`      // days to keep price history (default: 30)
+	HistorySnapshotMinutes    int     `json:"history_snapshot_minutes"`    // interval of recording prices in price history (default: 60)
+	InlineResultButtons       bool    `json:"inline_result_buttons"`       // attach buttons for refreshing price and showing details to inline query results or not
+	ValidateThumbnails        bool    `json:"validate_thumbnails"`         // omit thumbnails which fail to load from inline query results or not
+	ExcludeBasicCards         bool    `json:"exclude_basic_cards"`         // exclude basic (free) cards from collection or not
+	MaxListedItems            int     `json:"max_listed_items"`            // max number of items in a listed message (default: 30)
+	TextImageFontPath         string  `json:"text_image_font_path"`        // path of a truetype font file for rendering texts as images (should have glyphs of all languages)
+	StaleThresholdMinutes     int     `json:"stale_threshold_minutes"`     // age of market data over which it is noted as stale (default: cache ttl)
+	ImageThresholdChars       int     `json:"image_threshold_chars"`       // send messages longer than this as images (0 = disabled)
+	SuppressDuplicateCommands bool    `json:"suppress_duplicate_commands"` // ignore the same command sent again in a very short time (eg. double-tapped keyboard) or not
+	Currency                  string  `json:"currency,omitempty"`          // default currency of prices for chats which did not set their own (default: USD)
+	ParseMode                 string  `json:"parse_mode,omitempty"`        // parse mode of messages: "markdown" or "html" (default: "markdown")
+	SendImages                bool    `json:"send_images"`                 // send images of cards when a single card is shown or not
+	CacheMinutes              int     `json:"cache_minutes"`               // ttl of cached market items (default: 5)
+	WatchIntervalMinutes      int     `json:"watch_interval_minutes"`      // interval of checking watched prices (default: 10)
+	DailySummaryHour          int     `json:"daily_summary_hour"`          // hour of day (0-23, local time) when daily summaries are sent to subscribers
+	CachePath                 string  `json:"cache_path,omitempty"`        // file for persisting cached market items across restarts (relative to the executable, empty = disabled)
+	LogLevel                  string  `json:"log_level,omitempty"`         // min level of logs: "debug", "info", "warn", or "error" (default: "info")
+
+	// rate of tax/fee of the market (0-1, default: 0.15)
+	TaxRate *float32 `json:"tax_rate,omitempty"`
+
+	// number of retries of failed fetches of market items (0 = no retries, default: 3)
+	FetchRetries *int `json:"fetch_retries,omitempty"`
+
+	// names of hero cards (language code => names, replaces the built-in list of the language)
+	// (basic heroes, which are given for free, are suffixed with " (basic)")
+	Heroes map[string][]string `json:"heroes,omitempty"`
+
+	// emojis prefixed to rarities in messages (rarity keyword => emoji, eg. "rare" => "🟣")
+	RarityEmojis map[string]string `json:"rarity_emojis,omitempty"`
+
+	// cooldowns of commands per user (command => seconds)
+	CommandCooldownSeconds map[string]int `json:"command_cooldown_seconds,omitempty"`
+
+	// cache time of inline query results (language code => seconds, "*" for all languages)
+	InlineCacheSeconds map[string]int `json:"inline_cache_seconds,omitempty"`
+
+	// rows of commands on the reply keyboard (default: summarize and help)
+	Keyboard [][]string `json:"keyboard,omitempty"`
+
+	// summaries of rarities sent at fixed times every day
+	ScheduledRaritySummaries []scheduledRaritySummary `json:"scheduled_rarity_summaries,omitempty"`
+
+	// notes for outdated market data (language code => note, default: "(data may be stale)")
+	StaleNotes map[string]string `json:"stale_notes,omitempty"`
+
+	// messages for unknown commands (chat type => language code => message, empty message = no reply)
+	FallbackMessages map[string]map[string]string `json:"fallback_messages,omitempty"`
+
+	// webhook (long-polling is used when `webhook_url` is empty)
+	WebhookURL      string `json:"webhook_url,omitempty"`       // public url of webhook (eg. "https://bot.example.com:8443")
+	WebhookPort     int    `json:"webhook_port,omitempty"`      // local port for serving webhook (behind a reverse proxy)
+	WebhookCertPath string `json:"webhook_cert_path,omitempty"` // path of self-signed certificate to upload (optional)
 }
 
 var _conf config
+var _confLock sync.RWMutex
 var _botName string
+var _client *t.Bot // for sending messages outside of update handlers
 var _lock sync.RWMutex
 var _items map[a.Lang][]a.MarketItem   // market items
 var _itemsUpdated map[a.Lang]time.Time // times when market items were updated successfully
+var _lastPolled int64                  // unix nanoseconds of the last successful poll of updates (accessed atomically)
+var _startTime time.Time               // time when the bot started
+
+// pattern of Telegram bot tokens ("[bot id]:[secret]")
+var _tokenPattern = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]+$`)
+
+// pattern of currency codes (ISO 4217)
+var _currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// (non-admin) commands
+var _commands []string
+var _localizedCommandDescriptions map[a.Lang]map[string]string // one-line descriptions of `_commands`
+
+// admin commands
+var _adminCommands []string
+
+// supported languages and their codes
+var _languages []a.Lang
+var _languageFallbacks map[a.Lang][]a.Lang // language => languages to fall back to, in order
+var _languageCodes map[a.Lang]string
 
 // localized constants
+var _builtInHeroes map[a.Lang][]string // names of heroes, with `basicHeroSuffix` for basic ones
 var _localizedHeroes map[a.Lang][]string
+var _heroSets map[a.Lang]map[string]struct{}  // sets of `_localizedHeroes` for fast lookup
+var _localizedBasicHeroes map[a.Lang][]string // basic heroes which are given for free
+var _basicHeroSets map[a.Lang]map[string]struct{}
+var _localizedHeroTypePatterns map[a.Lang]*regexp.Regexp // patterns of hero-like types (for finding missing heroes)
+var _localizedHeroNamePatterns map[a.Lang]*regexp.Regexp // patterns of hero-like names (for finding missing heroes)
 var _localizedRarities map[a.Lang]map[a.Rarity]string
+var _raritiesOfTypes map[a.Lang]map[string]a.Rarity // reverse lookup of `_localizedRarities`
+var _localizedCollectionModes map[a.Lang]map[collectionMode]string
+
+// chat actions for commands (or commands with arguments) which don't just send text messages
+var _commandChatActions map[string]t.ChatAction
+
+// commands which always show previews of links in their messages
+var _commandsWithWebPagePreviews map[string]bool
+
+// keywords of rarities in command arguments
+var _rarityKeywords map[string]a.Rarity
 
 // initialize things
 func init() {
-	_conf = readConfig()
 	_lock = sync.RWMutex{}
 	_items = map[a.Lang][]a.MarketItem{}
 	_itemsUpdated = map[a.Lang]time.Time{}
 
+	_commands = []string{
+		commandStart,
+		commandSummarize,
+		commandAffordable,
+		commandNearby,
+		commandSetCurrency,
+		commandNotifyNew,
+		commandSubscribe,
+		commandUnsubscribe,
+		commandExtremes,
+		commandTrendChart,
+		commandChanges,
+		commandCompletion,
+		commandTop,
+		commandCheapest,
+		commandMeta,
+		commandHeroSummary,
+		commandCard,
+		commandOwn,
+		commandRemaining,
+		commandDeckCost,
+		commandWatch,
+		commandWatchlist,
+		commandUnwatch,
+		commandConvert,
+		commandTax,
+		commandExport,
+		commandDefault,
+		commandLang,
+		commandMySettings,
+		commandAssumptions,
+		commandResetSettings,
+		commandHelp,
+		commandCommands,
+	}
+
+	_adminCommands = []string{
+		commandReport,
+		commandStats,
+		commandResetStats,
+		commandReloadConfig,
+		commandReload,
+		commandRaw,
+		commandSuspectHero,
+	}
+
+	_localizedCommandDescriptions = map[a.Lang]map[string]string{
+		a.LangEnglish: map[string]string{
+			commandStart:         "Start the bot",
+			commandSummarize:     "Summarize current market information",
+			commandAffordable:    "List affordable cards of a rarity",
+			commandNearby:        "List cards priced close to a price",
+			commandSetCurrency:   "Set currencies for displaying prices",
+			commandNotifyNew:     "Get notified of new card types",
+			commandSubscribe:     "Get the market summary every day",
+			commandUnsubscribe:   "Stop getting the daily market summary",
+			commandExtremes:      "Show all-time lowest and highest prices",
+			commandTrendChart:    "Show a chart of full collection's price",
+			commandChanges:       "Show cards whose types changed recently",
+			commandCompletion:    "List cards which cost the most to complete",
+			commandTop:           "List the most expensive cards",
+			commandCheapest:      "List the least expensive cards",
+			commandMeta:          "Show an overview of heroes' prices",
+			commandHeroSummary:   "Summarize the price of hero cards",
+			commandCard:          "Show details of a card",
+			commandOwn:           "Manage cards you own",
+			commandRemaining:     "Calculate the remaining cost to complete",
+			commandDeckCost:      "Calculate the cost of a deck",
+			commandWatch:         "Get alerted when a card's price reaches a price",
+			commandWatchlist:     "List watched prices",
+			commandUnwatch:       "Stop watching a card's price",
+			commandConvert:       "Convert money between currencies",
+			commandTax:           "Calculate tax/fee for an amount",
+			commandExport:        "Export current market data as a file",
+			commandDefault:       "Set what to do with non-command texts",
+			commandLang:          "Set the language of messages",
+			commandMySettings:    "Show your settings",
+			commandAssumptions:   "Show assumptions used for calculating prices",
+			commandResetSettings: "Reset your settings",
+			commandHelp:          "Show help message",
+			commandCommands:      "List all commands",
+		},
+		a.LangKorean: map[string]string{
+			commandStart:         "봇을 시작합니다",
+			commandSummarize:     "현재 장터 정보를 요약합니다",
+			commandAffordable:    "주어진 등급의 저렴한 카드를 표시합니다",
+			commandNearby:        "주어진 가격에 가까운 카드를 표시합니다",
+			commandSetCurrency:   "가격을 표시할 통화를 설정합니다",
+			commandNotifyNew:     "새로운 카드 종류 알림을 받습니다",
+			commandSubscribe:     "매일 장터 정보 요약을 받습니다",
+			commandUnsubscribe:   "매일 받는 장터 정보 요약을 중지합니다",
+			commandExtremes:      "역대 최저가와 최고가를 표시합니다",
+			commandTrendChart:    "풀 컬렉션 수집 비용 차트를 표시합니다",
+			commandChanges:       "최근 종류가 바뀐 카드를 표시합니다",
+			commandCompletion:    "컬렉션 완성 비용이 큰 카드를 표시합니다",
+			commandTop:           "가장 비싼 카드를 표시합니다",
+			commandCheapest:      "가장 저렴한 카드를 표시합니다",
+			commandMeta:          "영웅 카드의 가격 개요를 표시합니다",
+			commandHeroSummary:   "영웅 카드의 가격을 요약합니다",
+			commandCard:          "카드의 상세 정보를 표시합니다",
+			commandOwn:           "보유한 카드를 관리합니다",
+			commandRemaining:     "남은 컬렉션 완성 비용을 계산합니다",
+			commandDeckCost:      "덱의 비용을 계산합니다",
+			commandWatch:         "카드 가격이 주어진 가격에 도달하면 알림을 받습니다",
+			commandWatchlist:     "가격 알림 목록을 표시합니다",
+			commandUnwatch:       "카드의 가격 알림을 중지합니다",
+			commandConvert:       "금액을 다른 통화로 환산합니다",
+			commandTax:           "금액에 대한 세금/수수료를 계산합니다",
+			commandExport:        "현재 장터 정보를 파일로 내보냅니다",
+			commandDefault:       "명령어가 아닌 텍스트에 대한 동작을 설정합니다",
+			commandLang:          "메시지의 언어를 설정합니다",
+			commandMySettings:    "설정을 표시합니다",
+			commandAssumptions:   "가격 계산의 전제 조건을 표시합니다",
+			commandResetSettings: "설정을 초기화합니다",
+			commandHelp:          "도움말을 표시합니다",
+			commandCommands:      "모든 명령어를 표시합니다",
+		},
+		// TODO - add more localizations here
+	}
+
+	_languages = []a.Lang{
+		a.LangEnglish,
+		a.LangKorean,
+		// TODO - add more languages here
+	}
+	_languageCodes = map[a.Lang]string{
+		a.LangEnglish: "en",
+		a.LangKorean:  "ko",
+		// TODO - add more language codes here
+	}
+
+	// fallback chains of languages which lack localized data
+	// (English is always the last resort, so it can be omitted)
+	_languageFallbacks = map[a.Lang][]a.Lang{
+		// TODO - add fallback chains here (eg. regional variant => base language)
+	}
+
 	// localized variables
-	_localizedHeroes = map[a.Lang][]string{
+	// (built-in heroes, overridden by `heroes` in config)
+	_builtInHeroes = map[a.Lang][]string{
 		a.LangEnglish: []string{
 			"Axe",
 			"Bristleback",
@@ -148,16 +856,16 @@ func init() {
 			"Magnus",
 			"Sven",
 			"Dark Seer",
-			"Debbi the Cunning", // basic
+			"Debbi the Cunning" + basicHeroSuffix,
 			"Mazzie",
-			"J'Muy the Wise",       // basic
-			"Fahrvhan the Dreamer", // basic
+			"J'Muy the Wise" + basicHeroSuffix,
+			"Fahrvhan the Dreamer" + basicHeroSuffix,
 			"Necrophos",
 			"Centaur Warrunner",
 			"Abaddon",
 			"Viper",
 			"Timbersaw",
-			"Keefe the Bold", // basic
+			"Keefe the Bold" + basicHeroSuffix,
 			"Tidehunter",
 			"Crystal Maiden",
 			"Bloodseeker",
@@ -199,16 +907,16 @@ func init() {
 			"마그누스",
 			"스벤",
 			"어둠 현자",
-			"교활한 데비", // basic
+			"교활한 데비" + basicHeroSuffix,
 			"매지",
-			"현자 제이무이",              // basic
-			"Fahrvhan the Dreamer", // basic
+			"현자 제이무이" + basicHeroSuffix,
+			"Fahrvhan the Dreamer" + basicHeroSuffix,
 			"강령사제",
 			"켄타우로스 전쟁용사",
 			"아바돈",
 			"바이퍼",
 			"벌목꾼",
-			"Keefe the Bold", // basic
+			"Keefe the Bold" + basicHeroSuffix,
 			"파도사냥꾼",
 			"수정의 여인",
 			"혈귀",
@@ -222,6 +930,17 @@ func init() {
 		},
 		// TODO - add more localizations here
 	}
+	setHeroes(_builtInHeroes)
+
+	_localizedHeroTypePatterns = map[a.Lang]*regexp.Regexp{
+		a.LangEnglish: regexp.MustCompile(`(?i)\bhero\b`),
+		a.LangKorean:  regexp.MustCompile(`영웅`),
+		// TODO - add more localizations here
+	}
+	_localizedHeroNamePatterns = map[a.Lang]*regexp.Regexp{
+		a.LangEnglish: regexp.MustCompile(`^\S+ the \S+$`), // eg. "Keefe the Bold"
+		// TODO - add more localizations here
+	}
 
 	_localizedRarities = map[a.Lang]map[a.Rarity]string{
 		a.LangEnglish: map[a.Rarity]string{
@@ -236,188 +955,309 @@ func init() {
 		},
 		// TODO - add more localizations here
 	}
+	_raritiesOfTypes = raritiesOfTypesFrom(_localizedRarities)
+
+	_commandChatActions = map[string]t.ChatAction{
+		commandExport:     t.ChatActionUploadDocument,
+		commandTrendChart: t.ChatActionUploadPhoto,
+		commandCard:       t.ChatActionUploadPhoto,
+		commandTop:        t.ChatActionUploadPhoto,
+		commandCheapest:   t.ChatActionUploadPhoto,
+
+		commandHelp + " image": t.ChatActionUploadPhoto,
+	}
+
+	_commandsWithWebPagePreviews = map[string]bool{
+		commandCard: true,
+	}
+
+	_rarityKeywords = map[string]a.Rarity{
+		"common":   a.RarityCommon,
+		"uncommon": a.RarityUncommon,
+		"rare":     a.RarityRare,
+		"일반":       a.RarityCommon,
+		"고급":       a.RarityUncommon,
+		"희귀":       a.RarityRare,
+		// TODO - add more localized keywords here
+	}
+
+	_localizedCollectionModes = map[a.Lang]map[collectionMode]string{
+		a.LangEnglish: map[collectionMode]string{
+			collectionModePlayset: "full playsets",
+			collectionModeSingles: "one of each",
+		},
+		a.LangKorean: map[collectionMode]string{
+			collectionModePlayset: "플레이세트",
+			collectionModeSingles: "종류별 1장",
+		},
+		// TODO - add more localizations here
+	}
 }
 
-// read config file
-func readConfig() config {
-	var err error
+// load config and persisted data, and apply config to localized variables
+//
+// (separated from `init`, so that tests can set things up without files)
+func setup() {
+	_conf = readConfig()
+	_state = loadState()
+	_savedUpdateOffset = _state.UpdateOffset
+	_history = loadHistory()
 
-	var execFilepath string
-	if execFilepath, err = os.Executable(); err == nil {
-		var file []byte
-		if file, err = ioutil.ReadFile(filepath.Join(filepath.Dir(execFilepath), confFilename)); err == nil {
-			var conf config
-			if err = json.Unmarshal(file, &conf); err == nil {
-				return conf
-			}
-		}
+	// heroes in config
+	setHeroes(heroesWithConfigured(_builtInHeroes, conf().Heroes))
+
+	// validate config values which depend on localized variables
+	if err := validateConfig(conf()); err != nil {
+		log.Fatalf("Invalid config file '%s': %s", confFilename, err)
 	}
 
-	panic(err)
+	// load persisted market items
+	loadItemsCache()
 }
 
-// get help message
-func getHelp(language a.Lang) string {
-	if language == a.LangKorean {
-		return fmt.Sprintf(messageHelpKor, commandSummarize, commandHelp, _botName)
+// get path of given filename in the directory of the executable
+//
+// (absolute paths are returned as they are)
+func filepathNextToExecutable(filename string) (string, error) {
+	if filepath.IsAbs(filename) {
+		return filename, nil
+	}
+
+	execFilepath, err := os.Executable()
+	if err != nil {
+		return "", err
 	}
 
-	// default = English
-	return fmt.Sprintf(messageHelpEng, commandSummarize, commandHelp, _botName)
+	return filepath.Join(filepath.Dir(execFilepath), filename), nil
 }
 
-// get message options
-func getMessageOptions() t.OptionsSendMessage {
-	return t.OptionsSendMessage{}.
-		SetReplyMarkup(t.ReplyKeyboardMarkup{
-			Keyboard: [][]t.KeyboardButton{
-				t.NewKeyboardButtons(commandSummarize, commandHelp),
-			},
-			ResizeKeyboard: true,
-		}).
-		SetParseMode(t.ParseModeMarkdown)
+// read config file (exits on error)
+func readConfig() config {
+	conf, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Failed to read config file '%s': %s", confFilename, err)
+	}
+
+	return conf
 }
 
-// get items
-func getItems(language a.Lang) []a.MarketItem {
-	_lock.Lock()
-	defer _lock.Unlock()
+// load config file
+func loadConfig() (conf config, err error) {
+	var confFilepath string
+	if confFilepath, err = filepathNextToExecutable(confFilename); err == nil {
+		var file []byte
+		if file, err = ioutil.ReadFile(confFilepath); err == nil {
+			err = json.Unmarshal(file, &conf)
+		}
+	}
+
+	return conf, err
+}
 
-	needsReload := false
+// validate given config
+//
+// returns an error which lists all the problems found
+func validateConfig(c config) error {
+	problems := []string{}
+
+	// token
+	if c.Token == "" {
+		problems = append(problems, "token is missing")
+	} else if !_tokenPattern.MatchString(c.Token) {
+		problems = append(problems, "token is malformed (should be in '[bot id]:[secret]' format)")
+	}
 
-	// check last updated time,
-	if updated, exists := _itemsUpdated[language]; exists {
-		// if it is outdated,
-		if updated.Add(cacheMinutes * time.Minute).Before(time.Now()) {
-			needsReload = true
+	// ranges of numbers
+	if c.MonitorIntervalSeconds <= 0 {
+		problems = append(problems, fmt.Sprintf("monitor_interval_seconds should be positive: %d", c.MonitorIntervalSeconds))
+	}
+	if c.WatchdogTimeoutSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("watchdog_timeout_seconds should not be negative: %d", c.WatchdogTimeoutSeconds))
+	}
+	if c.HistoryRetentionDays < 0 {
+		problems = append(problems, fmt.Sprintf("history_retention_days should not be negative: %d", c.HistoryRetentionDays))
+	}
+	if c.HistorySnapshotMinutes < 0 {
+		problems = append(problems, fmt.Sprintf("history_snapshot_minutes should not be negative: %d", c.HistorySnapshotMinutes))
+	}
+	if c.StaleThresholdMinutes < 0 {
+		problems = append(problems, fmt.Sprintf("stale_threshold_minutes should not be negative: %d", c.StaleThresholdMinutes))
+	}
+	for code := range c.StaleNotes {
+		if _, exists := langFromCode(code); !exists {
+			problems = append(problems, fmt.Sprintf("no localization for language in stale_notes: '%s'", code))
+		}
+	}
+	if c.ImageThresholdChars < 0 {
+		problems = append(problems, fmt.Sprintf("image_threshold_chars should not be negative: %d", c.ImageThresholdChars))
+	}
+	if c.DailySummaryHour < 0 || c.DailySummaryHour > 23 {
+		problems = append(problems, fmt.Sprintf("daily_summary_hour should be in 0-23: %d", c.DailySummaryHour))
+	}
+	if c.WatchIntervalMinutes < 0 {
+		problems = append(problems, fmt.Sprintf("watch_interval_minutes should not be negative: %d", c.WatchIntervalMinutes))
+	}
+	if c.FetchRetries != nil && *c.FetchRetries < 0 {
+		problems = append(problems, fmt.Sprintf("fetch_retries should not be negative: %d", *c.FetchRetries))
+	}
+	if c.MaxListedItems < 0 {
+		problems = append(problems, fmt.Sprintf("max_listed_items should not be negative: %d", c.MaxListedItems))
+	}
+	for command, seconds := range c.CommandCooldownSeconds {
+		if !isKnownCommand(command) && !isAdminCommand(command) {
+			problems = append(problems, fmt.Sprintf("unknown command in command_cooldown_seconds: '%s'", command))
+		}
+		if seconds < 0 {
+			problems = append(problems, fmt.Sprintf("cooldown of '%s' should not be negative: %d", command, seconds))
 		}
-	} else {
-		needsReload = true
 	}
 
-	// reload,
-	if needsReload {
-		items, err := a.FetchAll(a.RarityAll, language, a.SortColumnName, a.SortDirectionAsc)
+	// admins
+	for _, id := range c.AdminChatIDs {
+		if id == 0 {
+			problems = append(problems, "admin_chat_ids should not contain 0")
+		}
+	}
 
-		if err == nil {
-			// update values
-			_items[language] = items
-			_itemsUpdated[language] = time.Now()
+	// urls
+	if c.ExchangeRatesURL != "" {
+		if u, err := url.Parse(c.ExchangeRatesURL); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("exchange_rates_url is not a valid url: '%s'", c.ExchangeRatesURL))
+		}
+	}
 
-			return items
+	if mode := strings.ToLower(c.ParseMode); mode != "" && mode != parseModeMarkdown && mode != parseModeHTML {
+		problems = append(problems, fmt.Sprintf("unknown parse_mode: '%s'", c.ParseMode))
+	}
+
+	if level := strings.ToLower(c.LogLevel); level != "" {
+		if _, exists := _logLevelNames[level]; !exists {
+			problems = append(problems, fmt.Sprintf("unknown log_level: '%s'", c.LogLevel))
 		}
+	}
 
-		log.Printf("Failed to reload items (%s): %s", language, err)
-	} else {
-		// return cached items
-		return _items[language]
+	if c.TaxRate != nil && (*c.TaxRate < 0 || *c.TaxRate > 1) {
+		problems = append(problems, fmt.Sprintf("tax_rate should be in 0-1: %g", *c.TaxRate))
 	}
 
-	// return empty slice on error
-	return []a.MarketItem{}
-}
+	if c.Currency != "" && !_currencyCodePattern.MatchString(strings.ToUpper(c.Currency)) {
+		problems = append(problems, fmt.Sprintf("currency is not a valid currency code: '%s'", c.Currency))
+	}
 
-// get market summary
-func getSummary(language a.Lang) string {
-	var numItems,
-		numCommons, numCommonCards, priceCommons,
-		numUncommons, numUncommonCards, priceUncommons,
-		numRares, numRareCards, priceRares int
+	if c.ProxyURL != "" {
+		if _, err := parseProxyURL(c.ProxyURL); err != nil {
+			problems = append(problems, fmt.Sprintf("proxy_url is not valid: %s", err))
+		}
+	}
 
-	// calculate values
-	for _, item := range getItems(language) {
-		numItems++
+	if c.WebhookURL != "" {
+		if _, _, err := parseWebhookURL(c.WebhookURL); err != nil {
+			problems = append(problems, fmt.Sprintf("webhook_url is not valid: %s", err))
+		}
+		if c.WebhookPort <= 0 || c.WebhookPort > 65535 {
+			problems = append(problems, fmt.Sprintf("webhook_port is not a valid port: %d", c.WebhookPort))
+		}
+		if c.WebhookCertPath != "" {
+			if _, err := os.Stat(c.WebhookCertPath); err != nil {
+				problems = append(problems, fmt.Sprintf("webhook_cert_path is not accessible: %s", err))
+			}
+		}
+	}
 
-		// number of cards per item
-		numCards := maxNumCardsPerDeck
-		if isHero(item.Name, language) {
-			numCards = maxNumHeroCardsPerDeck
+	// languages should have localizations
+	for code, seconds := range c.InlineCacheSeconds {
+		if _, exists := langFromCode(code); code != "*" && !exists {
+			problems = append(problems, fmt.Sprintf("no localization for language in inline_cache_seconds: '%s'", code))
+		}
+		if seconds < 0 {
+			problems = append(problems, fmt.Sprintf("inline cache seconds of '%s' should not be negative: %d", code, seconds))
+		}
+	}
+	for chatType, messages := range c.FallbackMessages {
+		for code := range messages {
+			if _, exists := langFromCode(code); !exists {
+				problems = append(problems, fmt.Sprintf("no localization for language in fallback_messages of '%s': '%s'", chatType, code))
+			}
 		}
+	}
 
-		// check rarity
-		switch rarityOf(item, language) {
-		case a.RarityCommon:
-			numCommons++
-			numCommonCards += numCards
-			priceCommons += item.SellPrice * numCards
-		case a.RarityUncommon:
-			numUncommons++
-			numUncommonCards += numCards
-			priceUncommons += item.SellPrice * numCards
-		case a.RarityRare:
-			numRares++
-			numRareCards += numCards
-			priceRares += item.SellPrice * numCards
+	// rarities
+	for keyword := range c.RarityEmojis {
+		if _, exists := _rarityKeywords[keyword]; !exists {
+			problems = append(problems, fmt.Sprintf("unknown rarity in rarity_emojis: '%s'", keyword))
 		}
 	}
 
-	total := float32(priceCommons+priceUncommons+priceRares) / 100.0
-	tax := taxOf(total)
+	// heroes
+	for code, names := range c.Heroes {
+		if _, exists := langFromCode(code); !exists {
+			problems = append(problems, fmt.Sprintf("no localization for language in heroes: '%s'", code))
+		}
+		for _, name := range names {
+			if strings.TrimSpace(strings.TrimSuffix(name, basicHeroSuffix)) == "" {
+				problems = append(problems, fmt.Sprintf("empty hero name in heroes of language: '%s'", code))
+				break
+			}
+		}
+	}
 
-	// last updated time
-	_lock.RLock()
-	var lastUpdated time.Time
-	var exists bool
-	if lastUpdated, exists = _itemsUpdated[language]; !exists {
-		lastUpdated = time.Time{}
+	// schedules
+	problems = append(problems, validateSchedules(c.ScheduledRaritySummaries)...)
+
+	// commands on keyboard
+	for _, row := range c.Keyboard {
+		for _, command := range row {
+			if !isKnownCommand(command) {
+				problems = append(problems, fmt.Sprintf("unknown command on keyboard: '%s'", command))
+			}
+		}
 	}
-	_lock.RUnlock()
 
-	// localized summary format
-	summary := messageSummaryEng
-	if language == a.LangKorean {
-		summary = messageSummaryKor
+	if len(problems) > 0 {
+		sort.Strings(problems)
+
+		return fmt.Errorf("%d problem(s) found:\n- %s", len(problems), strings.Join(problems, "\n- "))
 	}
 
-	return fmt.Sprintf(summary,
-		numItems,
-		numCommons, numCommonCards, float32(priceCommons)/100.0,
-		numUncommons, numUncommonCards, float32(priceUncommons)/100.0,
-		numRares, numRareCards, float32(priceRares)/100.0,
-		total, tax, total+tax,
-		lastUpdated.UTC().Format(timestampFormat),
-	)
+	return nil
 }
 
-// search items by name (ignore case)
-func searchItemsByName(name string, language a.Lang) []a.MarketItem {
-	results := []a.MarketItem{}
-
-	for _, item := range getItems(language) {
-		if strings.Contains(strings.ToLower(item.Name), strings.ToLower(name)) {
-			results = append(results, item)
+// check if given command is one of (non-admin) commands
+func isKnownCommand(command string) bool {
+	for _, c := range _commands {
+		if c == command {
+			return true
 		}
 	}
 
-	return results
+	return false
 }
 
-// check language from given Telegram user
-func langFromUser(u *t.User) a.Lang {
-	if u != nil {
-		langCode := u.LanguageCode
-
-		if langCode != nil {
-			if strings.HasPrefix(*langCode, "ko") {
-				return a.LangKorean
-			}
-			// TODO - add more languages here
+// check if given command is one of admin commands
+func isAdminCommand(command string) bool {
+	for _, c := range _adminCommands {
+		if c == command {
+			return true
 		}
 	}
 
-	return a.LangEnglish // default
+	return false
 }
 
-// check if a card with given name is a hero
-func isHero(name string, language a.Lang) bool {
-	if _, exists := _localizedHeroes[language]; !exists {
-		log.Printf("* No heroes defined for language: %s", language)
-
-		return false
+// check if given text will be handled with a command, or with the default action of given chat
+//
+// (fallback messages are not, as they are sent at once or not sent at all)
+func isHandledText(txt string, chatID int64) bool {
+	if len(txt) > 0 && !strings.HasPrefix(txt, "/") {
+		return defaultActionOf(chatID) != defaultActionNone
 	}
 
-	for _, hero := range _localizedHeroes[language] {
-		if hero == name {
+	commands := _commands
+	if isAdmin(chatID) {
+		commands = append(append([]string{}, _commands...), _adminCommands...)
+	}
+	for _, c := range commands {
+		if strings.HasPrefix(txt, c) {
 			return true
 		}
 	}
@@ -425,111 +1265,2347 @@ func isHero(name string, language a.Lang) bool {
 	return false
 }
 
-// get rarity of given item
-func rarityOf(item a.MarketItem, language a.Lang) a.Rarity {
-	itemType := item.AssetDescription.Type
-	rarities := _localizedRarities[language]
+// get current config
+func conf() config {
+	_confLock.RLock()
+	defer _confLock.RUnlock()
 
-	for k, v := range rarities {
-		if itemType == v {
-			return k
-		}
+	return _conf
+}
+
+// reload config file, and apply the values which can be changed at runtime
+//
+// returns names of fields whose changes need a restart
+func reloadConfig() (needsRestart []string, err error) {
+	reloaded, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConfig(reloaded); err != nil {
+		return nil, err
 	}
 
-	return a.RarityAll // unknown rarity
-}
+	_confLock.Lock()
+	defer _confLock.Unlock()
 
-// calculate tax of given price
-func taxOf(price float32) float32 {
-	return 0.15 * price
+	// keep values which cannot be changed at runtime
+	if reloaded.Token != _conf.Token {
+		needsRestart = append(needsRestart, "token")
+		reloaded.Token = _conf.Token
+	}
+	if reloaded.MonitorIntervalSeconds != _conf.MonitorIntervalSeconds {
+		needsRestart = append(needsRestart, "monitor_interval_seconds")
+		reloaded.MonitorIntervalSeconds = _conf.MonitorIntervalSeconds
+	}
+	if reloaded.Verbose != _conf.Verbose {
+		needsRestart = append(needsRestart, "verbose")
+		reloaded.Verbose = _conf.Verbose
+	}
+	if reloaded.WatchdogTimeoutSeconds != _conf.WatchdogTimeoutSeconds {
+		needsRestart = append(needsRestart, "watchdog_timeout_seconds")
+		reloaded.WatchdogTimeoutSeconds = _conf.WatchdogTimeoutSeconds
+	}
+	if reloaded.ProxyURL != _conf.ProxyURL {
+		needsRestart = append(needsRestart, "proxy_url")
+		reloaded.ProxyURL = _conf.ProxyURL
+	}
+	if reloaded.WebhookURL != _conf.WebhookURL || reloaded.WebhookPort != _conf.WebhookPort || reloaded.WebhookCertPath != _conf.WebhookCertPath {
+		needsRestart = append(needsRestart, "webhook_url/webhook_port/webhook_cert_path")
+		reloaded.WebhookURL, reloaded.WebhookPort, reloaded.WebhookCertPath = _conf.WebhookURL, _conf.WebhookPort, _conf.WebhookCertPath
+	}
+	if !reflect.DeepEqual(reloaded.Heroes, _conf.Heroes) {
+		needsRestart = append(needsRestart, "heroes")
+		reloaded.Heroes = _conf.Heroes
+	}
+	if !reflect.DeepEqual(reloaded.ScheduledRaritySummaries, _conf.ScheduledRaritySummaries) {
+		needsRestart = append(needsRestart, "scheduled_rarity_summaries")
+		reloaded.ScheduledRaritySummaries = _conf.ScheduledRaritySummaries
+	}
+
+	_conf = reloaded
+
+	return needsRestart, nil
 }
 
-// process incoming updates with this function
-func processUpdate(b *t.Bot, update t.Update) bool {
-	// process result
-	result := false
+// get result message of reloading config
+func getReloadConfigResult() string {
+	needsRestart, err := reloadConfig()
+	if err != nil {
+		logErrorf("Failed to reload config: %s", err)
 
-	// text from message
-	var txt string
-	if update.Message.HasText() {
-		txt = *update.Message.Text
-	} else {
-		txt = ""
+		return fmt.Sprintf(messageReloadConfigFailed, err)
 	}
 
-	language := langFromUser(update.Message.From)
+	logInfof("Config was reloaded")
 
-	var message string
+	if len(needsRestart) > 0 {
+		return fmt.Sprintf(messageReloadConfigNeedsRestart, strings.Join(needsRestart, ", "))
+	}
+	return messageReloadConfig
+}
 
-	switch {
-	// start
-	case strings.HasPrefix(txt, commandStart):
-		message = getHelp(language)
-		// summarize
-	case strings.HasPrefix(txt, commandSummarize):
-		message = getSummary(language)
-	// help
-	case strings.HasPrefix(txt, commandHelp):
-		message = getHelp(language)
-	// fallback
-	default:
-		if len(txt) > 0 {
-			message = fmt.Sprintf("*%s*: %s", txt, messageUnknownCommand)
-		} else {
-			message = messageUnknownCommand
+// invalidate caches of market items and fetch them again
+//
+// (all languages when `code` is empty)
+func getReloadItemsResult(code string) string {
+	languages := _languages
+	if len(code) > 0 {
+		language, exists := langFromCode(code)
+		if !exists {
+			return fmt.Sprintf(messageReloadItemsUnknownLang, escapeMarkdown(code))
 		}
+		languages = []a.Lang{language}
 	}
 
-	if len(message) > 0 {
-		// 'typing...'
-		b.SendChatAction(update.Message.Chat.ID, t.ChatActionTyping)
-
-		// send message
-		if sent := b.SendMessage(update.Message.Chat.ID, message, getMessageOptions()); sent.Ok {
-			result = true
+	lines := []string{}
+	for _, language := range languages {
+		// fetch again regardless of the cache (cached items are kept on failures)
+		if items, err := loadItems(language, true); err == nil {
+			lines = append(lines, fmt.Sprintf(messageReloadItemsLanguage, language, len(items), lastUpdatedOf(language).UTC().Format(timestampFormat)))
 		} else {
-			log.Printf("Failed to send message: %s", *sent.Description)
+			lines = append(lines, fmt.Sprintf(messageReloadItemsFailed, language))
 		}
 	}
 
-	return result
+	logInfof("Items were reloaded: %s", strings.Join(lines, ", "))
+
+	return fmt.Sprintf(messageReloadItems, strings.Join(lines, "\n"))
 }
 
-// process inline query
-func processInlineQuery(b *t.Bot, update t.Update) bool {
-	language := langFromUser(&update.InlineQuery.From)
+// reload config on SIGHUP
+func reloadConfigOnSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
 
-	// query length limit differs between languages
-	queryLengthLimit := 3
-	if language == a.LangKorean {
-		queryLengthLimit = 1
+	for range ch {
+		logInfof("Received SIGHUP: %s", getReloadConfigResult())
 	}
-	// TODO - add more length limits for different languages
+}
 
-	query := strings.TrimSpace(update.InlineQuery.Query)
+// get help message
+func getHelp(language a.Lang) string {
+	return fmt.Sprintf(localized(language, messageHelpEng, messageHelpKor), commandSummarize, commandExtremes, commandTrendChart, commandChanges, commandCompletion, commandTop, commandCheapest, commandMeta, commandHeroSummary, commandCard, commandOwn, commandRemaining, commandDeckCost, commandWatch, commandWatchlist, commandUnwatch, commandAffordable, commandNearby, commandSetCurrency, commandNotifyNew, commandSubscribe, commandUnsubscribe, commandTax, commandConvert, commandExport, commandDefault, commandLang, commandMySettings, commandAssumptions, commandResetSettings, commandHelp, commandCommands, _botName)
+}
 
-	// when query is too short,
-	if len(query) < queryLengthLimit {
-		return false
+// get message options
+func getMessageOptions() t.OptionsSendMessage {
+	return t.OptionsSendMessage{}.
+		SetReplyMarkup(replyKeyboardMarkup()).
+		SetParseMode(parseMode()).
+		SetDisableWebPagePreview(!conf().ShowWebPagePreviews)
+}
+
+// get reply keyboard markup with commands in config
+func replyKeyboardMarkup() t.ReplyKeyboardMarkup {
+	keyboard := [][]t.KeyboardButton{}
+	for _, row := range conf().Keyboard {
+		keyboard = append(keyboard, t.NewKeyboardButtons(row...))
+	}
+	if len(keyboard) <= 0 {
+		keyboard = [][]t.KeyboardButton{
+			t.NewKeyboardButtons(commandSummarize, commandHelp),
+		}
+	}
+
+	return t.ReplyKeyboardMarkup{
+		Keyboard:       keyboard,
+		ResizeKeyboard: true,
+	}
+}
+
+// get items
+//
+// (cached items are returned when they are not outdated, otherwise they are fetched again)
+func getItems(language a.Lang) []a.MarketItem {
+	items, _ := loadItems(language, false)
+
+	return items
+}
+
+// load items from cache, or fetch them again when they are outdated or `force` is true
+//
+// (on fetch errors, returns cached items if any, along with the error)
+func loadItems(language a.Lang, force bool) ([]a.MarketItem, error) {
+	_lock.RLock()
+	cached := _items[language]
+	updated, exists := _itemsUpdated[language]
+	_lock.RUnlock()
+
+	// return cached items if they are not outdated,
+	if !force && exists && updated.Add(cacheTTL()).After(time.Now()) {
+		atomic.AddInt64(&_numCacheHits, 1)
+
+		return cached, nil
+	}
+
+	// or reload (concurrent reloads of the same language are coalesced into one)
+	atomic.AddInt64(&_numCacheMisses, 1)
+
+	items, err := fetchItems(language, func(items []a.MarketItem) {
+		// update values
+		_lock.Lock()
+		_items[language] = items
+		_itemsUpdated[language] = time.Now()
+		_lock.Unlock()
+
+		// persist them
+		saveItemsCache()
+
+		// check new item types
+		if _client != nil {
+			go checkNewTypes(_client, language, items)
+		}
+
+		// record all-time lowest/highest prices
+		//
+		// (from English items only as in price history, for prices of other languages can differ with their classifications)
+		if language == a.LangEnglish {
+			if price := collectionPriceOf(totalsOf(items, language, collectionModePlayset)); price > 0 {
+				recordCollectionPrice(price)
+			}
+		}
+
+		// record types of cards
+		if changes := recordCardTypes(language, items); len(changes) > 0 {
+			logInfof("Types of %d card(s) changed (%s)", len(changes), language)
+		}
+	})
+	if err == nil {
+		return items, nil
+	}
+
+	logWarnf("Failed to reload items (%s): %s", language, err)
+
+	// return outdated items on error (they are noted as stale in summaries),
+	if len(cached) > 0 {
+		return cached, err
+	}
+
+	// or empty slice when they were never fetched successfully
+	return []a.MarketItem{}, err
+}
+
+// fetch market items of all supported languages, so that the first requests do not wait for them
+//
+// (best-effort: failures are logged by `getItems`)
+func warmUpCaches() {
+	for _, language := range _languages {
+		if items := getItems(language); len(items) > 0 {
+			logInfof("Warmed up cache of %d item(s) (%s)", len(items), language)
+		}
+	}
+}
+
+// get recent changes of cards' types
+//
+// (paginated with inline buttons when there are too many changes)
+func getTypeChanges(language a.Lang) (string, *t.InlineKeyboardMarkup) {
+	changes := recentTypeChanges(language)
+	if len(changes) <= 0 {
+		return localized(language, messageChangesNoneEng, messageChangesNoneKor), nil
+	}
+
+	lines := []string{}
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("- %s: %s → *%s* (_%s_)", escapeMarkdown(change.Name), change.From, change.To, change.Time.UTC().Format(timestampFormat)))
+	}
+
+	return paginate(localized(language, messageChangesEng, messageChangesKor), lines, language)
+}
+
+// get summary of given rarity
+func getRaritySummary(chatID int64, language a.Lang, rarity a.Rarity, mode collectionMode) string {
+	currencies := currenciesOf(chatID)
+
+	total := totalsOf(getItems(language), language, mode)[rarity]
+	price := float32(total.price) / 100.0
+	tax := taxOf(price)
+
+	return fmt.Sprintf(localized(language, messageRaritySummaryEng, messageRaritySummaryKor),
+		rarityEmoji(rarity), _localizedRarities[language][rarity], _localizedCollectionModes[language][mode],
+		total.numItems, total.numCards,
+		formatPrices(price, currencies), formatPrices(tax, currencies), formatPrices(price+tax, currencies),
+		formatLastUpdated(lastUpdatedOf(language), language),
+	) + staleNoteOf(language)
+}
+
+// get summary of hero cards only
+func getHeroSummary(chatID int64, language a.Lang, mode collectionMode) string {
+	currencies := currenciesOf(chatID)
+
+	heroes := []a.MarketItem{}
+	resolved := map[string]bool{}
+	for _, item := range getItems(language) {
+		if isHero(item.Name, language) {
+			heroes = append(heroes, item)
+			resolved[item.Name] = true
+		}
+	}
+
+	var total rarityTotals
+	for _, totalOfRarity := range totalsOf(heroes, language, mode) {
+		total.numItems += totalOfRarity.numItems
+		total.numCards += totalOfRarity.numCards
+		total.price += totalOfRarity.price
+	}
+	price := float32(total.price) / 100.0
+	tax := taxOf(price)
+
+	// heroes which are not in the market
+	unresolved := []string{}
+	for _, name := range heroesOf(language) {
+		if !resolved[name] {
+			unresolved = append(unresolved, escapeMarkdown(name))
+		}
+	}
+	missing := ""
+	if len(unresolved) > 0 {
+		missing = fmt.Sprintf(localized(language, messageMetaUnresolvedEng, messageMetaUnresolvedKor), strings.Join(unresolved, ", "))
+	}
+
+	return fmt.Sprintf(localized(language, messageRaritySummaryEng, messageRaritySummaryKor),
+		"", localized(language, messageHeroesEng, messageHeroesKor), _localizedCollectionModes[language][mode],
+		total.numItems, total.numCards,
+		formatPrices(price, currencies), formatPrices(tax, currencies), formatPrices(price+tax, currencies),
+		formatLastUpdated(lastUpdatedOf(language), language),
+	) + staleNoteOf(language) + missing
+}
+
+// format given time of last update with its relative age (eg. "2006-01-02 ... (3 minutes ago)")
+func formatLastUpdated(updated time.Time, language a.Lang) string {
+	if updated.IsZero() {
+		return localized(language, messageNeverUpdatedEng, messageNeverUpdatedKor)
+	}
+
+	return fmt.Sprintf("%s (%s)", updated.UTC().Format(timestampFormat), formatAge(time.Since(updated), language))
+}
+
+// format given age in a localized, human-readable form
+func formatAge(age time.Duration, language a.Lang) string {
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf(localized(language, messageSecondsAgoEng, messageSecondsAgoKor), int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf(localized(language, messageMinutesAgoEng, messageMinutesAgoKor), int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf(localized(language, messageHoursAgoEng, messageHoursAgoKor), int(age.Hours()))
+	default:
+		return fmt.Sprintf(localized(language, messageDaysAgoEng, messageDaysAgoKor), int(age.Hours()/24))
+	}
+}
+
+// get a note for outdated items of given language (empty when they are not outdated)
+//
+// (threshold and notes can be configured with `stale_threshold_minutes` and `stale_notes`)
+func staleNoteOf(language a.Lang) string {
+	updated := lastUpdatedOf(language)
+	if updated.IsZero() {
+		return ""
+	}
+
+	threshold := time.Duration(conf().StaleThresholdMinutes) * time.Minute
+	if threshold <= 0 {
+		threshold = cacheTTL() // default = cache ttl
+	}
+	if time.Since(updated) <= threshold {
+		return ""
+	}
+
+	if note, exists := conf().StaleNotes[_languageCodes[language]]; exists {
+		return "\n" + note + "\n"
+	}
+	return localized(language, messageStaleEng, messageStaleKor)
+}
+
+// get the time when items of given language were updated (zero time if never updated)
+func lastUpdatedOf(language a.Lang) time.Time {
+	_lock.RLock()
+	defer _lock.RUnlock()
+
+	return _itemsUpdated[language]
+}
+
+// get all-time lowest and highest prices of full collection
+func getExtremes(language a.Lang, currencies []string) string {
+	lowest, highest := collectionPriceExtremes()
+	if lowest == nil || highest == nil {
+		return localized(language, messageExtremesNoneEng, messageExtremesNoneKor)
+	}
+
+	return fmt.Sprintf(localized(language, messageExtremesEng, messageExtremesKor),
+		formatPrices(float32(lowest.Price)/100.0, currencies), lowest.Time.UTC().Format(timestampFormat),
+		formatPrices(float32(highest.Price)/100.0, currencies), highest.Time.UTC().Format(timestampFormat),
+	)
+}
+
+// totals of items in a rarity
+type rarityTotals struct {
+	numItems int // number of items
+	numCards int // number of cards needed
+	price    int // price of all needed cards (in cents)
+}
+
+// calculate totals of given items per rarity
+//
+// (items of unknown rarity, and basic cards when configured so, are not counted)
+func totalsOf(items []a.MarketItem, language a.Lang, mode collectionMode) map[a.Rarity]rarityTotals {
+	return remainingTotalsOf(items, language, mode, nil)
+}
+
+// calculate totals of given items per rarity, excluding owned cards (hash name => number of owned cards)
+//
+// (items whose cards are all owned are not counted)
+func remainingTotalsOf(items []a.MarketItem, language a.Lang, mode collectionMode, owned map[string]int) map[a.Rarity]rarityTotals {
+	totals := map[a.Rarity]rarityTotals{}
+	excludeBasics := conf().ExcludeBasicCards
+
+	for _, item := range items {
+		rarity := rarityOf(item, language)
+		if rarity == a.RarityAll {
+			continue
+		}
+		if excludeBasics && isBasicHero(item.Name, language) {
+			continue
+		}
+
+		// number of cards per item
+		numCards := numCardsOf(item, language, mode) - owned[item.HashName]
+		if numCards <= 0 {
+			continue
+		}
+
+		total := totals[rarity]
+		total.numItems++
+		total.numCards += numCards
+		total.price += item.SellPrice * numCards
+		totals[rarity] = total
+	}
+
+	return totals
+}
+
+// sum prices of given totals (in cents)
+func collectionPriceOf(totals map[a.Rarity]rarityTotals) (price int) {
+	for _, total := range totals {
+		price += total.price
+	}
+
+	return price
+}
+
+// get market summary
+//
+// (only the summary of given rarity is returned when it is not `a.RarityAll`)
+//
+// this does not record a check of the chat, so it can be used for automated messages
+func getSummary(chatID int64, language a.Lang, mode collectionMode, rarity a.Rarity) string {
+	if rarity != a.RarityAll {
+		return getRaritySummary(chatID, language, rarity, mode)
+	}
+
+	return summaryOf(chatID, language, mode, "")
+}
+
+// get market summary requested by given chat, with the change since its last check
+//
+// (records this check, so it should be used only for explicit requests)
+func checkSummary(chatID int64, language a.Lang, mode collectionMode, rarity a.Rarity) string {
+	if rarity != a.RarityAll {
+		return getRaritySummary(chatID, language, rarity, mode)
+	}
+
+	return summaryOf(chatID, language, mode, recordCheck(chatID, language, mode))
+}
+
+// record the current price of full collection as the last check of given chat,
+// and get the change since the previous check ("" when there was none)
+func recordCheck(chatID int64, language a.Lang, mode collectionMode) string {
+	price := collectionPriceOf(totalsOf(getItems(language), language, mode))
+	if price <= 0 {
+		return ""
+	}
+
+	previous := swapLastCheckedPrice(chatID, mode, price)
+	if previous == nil {
+		return ""
+	}
+
+	currencies := currenciesOf(chatID)
+
+	sign, delta := "+", price-previous.Price
+	if delta < 0 {
+		sign, delta = "-", -delta
+	}
+	checked := previous.Time.UTC().Format(timestampFormat)
+
+	if localizedLanguageOf(language) == a.LangKorean {
+		return fmt.Sprintf(messageChangedSinceLastCheckKor, checked, sign, formatPrices(float32(delta)/100.0, currencies))
+	}
+	return fmt.Sprintf(messageChangedSinceLastCheckEng, sign, formatPrices(float32(delta)/100.0, currencies), checked)
+}
+
+// get summary of full collection, with given change since the last check
+func summaryOf(chatID int64, language a.Lang, mode collectionMode, changed string) string {
+	currencies := currenciesOf(chatID)
+
+	items := getItems(language)
+	totals := totalsOf(items, language, mode)
+	commons, uncommons, rares := totals[a.RarityCommon], totals[a.RarityUncommon], totals[a.RarityRare]
+
+	price := collectionPriceOf(totals)
+	total := float32(price) / 100.0
+	tax := taxOf(total)
+
+	// excluded basic cards
+	excluded := ""
+	if conf().ExcludeBasicCards {
+		numBasics := 0
+		for _, item := range items {
+			if isBasicHero(item.Name, language) {
+				numBasics++
+			}
+		}
+
+		if numBasics > 0 {
+			excluded = fmt.Sprintf(localized(language, messageBasicCardsExcludedEng, messageBasicCardsExcludedKor), numBasics)
+		}
+	}
+
+	// last updated time
+	lastUpdated := formatLastUpdated(lastUpdatedOf(language), language)
+
+	// localized summary format
+	summary := messageSummaryEng
+	if localizedLanguageOf(language) == a.LangKorean {
+		summary = messageSummaryKor
+	}
+
+	// warn when too many items could not be classified
+	warning := ""
+	numUnclassified := 0
+	for _, item := range items {
+		if rarityOf(item, language) == a.RarityAll {
+			numUnclassified++
+		}
+	}
+	if len(items) > 0 && float32(numUnclassified)/float32(len(items)) > maxUnclassifiedRatio {
+		logWarnf("* %d of %d items are not classified (%s)", numUnclassified, len(items), language)
+
+		warning = fmt.Sprintf(localized(language, messageUnclassifiedEng, messageUnclassifiedKor), numUnclassified)
+	}
+
+	return fmt.Sprintf(summary,
+		_localizedCollectionModes[language][mode],
+		len(items),
+		rarityEmoji(a.RarityCommon), commons.numItems, commons.numCards, formatPrices(float32(commons.price)/100.0, currencies),
+		rarityEmoji(a.RarityUncommon), uncommons.numItems, uncommons.numCards, formatPrices(float32(uncommons.price)/100.0, currencies),
+		rarityEmoji(a.RarityRare), rares.numItems, rares.numCards, formatPrices(float32(rares.price)/100.0, currencies),
+		formatPrices(total, currencies), formatPrices(tax, currencies), formatPrices(total+tax, currencies), excluded, changed,
+		lastUpdated,
+	) + staleNoteOf(language) + warning
+}
+
+// get an overview of heroes' prices
+func getMeta(chatID int64, language a.Lang) string {
+	currencies := currenciesOf(chatID)
+
+	heroes := []a.MarketItem{}
+	resolved := map[string]bool{}
+	for _, item := range getItems(language) {
+		if isHero(item.Name, language) && item.SellPrice > 0 {
+			heroes = append(heroes, item)
+			resolved[item.Name] = true
+		}
+	}
+
+	if len(heroes) <= 0 {
+		return localized(language, messageMetaNoneEng, messageMetaNoneKor)
+	}
+
+	sort.SliceStable(heroes, func(i, j int) bool {
+		return heroes[i].SellPrice < heroes[j].SellPrice
+	})
+	cheapest, priciest := heroes[0], heroes[len(heroes)-1]
+
+	sum := 0
+	for _, hero := range heroes {
+		sum += hero.SellPrice
+	}
+	average := float32(sum) / float32(len(heroes)) / 100.0
+
+	// heroes which are not in the market (or not priced)
+	unresolved := []string{}
+	tracked := heroesOf(language)
+	for _, name := range tracked {
+		if !resolved[name] {
+			unresolved = append(unresolved, escapeMarkdown(name))
+		}
+	}
+	missing := ""
+	if len(unresolved) > 0 {
+		missing = fmt.Sprintf(localized(language, messageMetaUnresolvedEng, messageMetaUnresolvedKor), strings.Join(unresolved, ", "))
+	}
+
+	return fmt.Sprintf(localized(language, messageMetaEng, messageMetaKor),
+		len(tracked), len(heroes),
+		escapeMarkdown(cheapest.Name), formatPrices(float32(cheapest.SellPrice)/100.0, currencies),
+		escapeMarkdown(priciest.Name), formatPrices(float32(priciest.SellPrice)/100.0, currencies),
+		formatPrices(average, currencies),
+		missing,
+	)
+}
+
+// get the cost to complete the collection, excluding cards owned by given chat
+func getRemaining(chatID int64, language a.Lang, mode collectionMode) string {
+	currencies := currenciesOf(chatID)
+
+	owned := ownedCardsOf(chatID)
+	numOwned := 0
+	for _, count := range owned {
+		numOwned += count
+	}
+
+	totals := remainingTotalsOf(getItems(language), language, mode, owned)
+	commons, uncommons, rares := totals[a.RarityCommon], totals[a.RarityUncommon], totals[a.RarityRare]
+
+	total := float32(collectionPriceOf(totals)) / 100.0
+	tax := taxOf(total)
+
+	return fmt.Sprintf(localized(language, messageRemainingEng, messageRemainingKor),
+		_localizedCollectionModes[language][mode],
+		numOwned,
+		rarityEmoji(a.RarityCommon), commons.numItems, commons.numCards, formatPrices(float32(commons.price)/100.0, currencies),
+		rarityEmoji(a.RarityUncommon), uncommons.numItems, uncommons.numCards, formatPrices(float32(uncommons.price)/100.0, currencies),
+		rarityEmoji(a.RarityRare), rares.numItems, rares.numCards, formatPrices(float32(rares.price)/100.0, currencies),
+		formatPrices(total, currencies), formatPrices(tax, currencies), formatPrices(total+tax, currencies),
+	)
+}
+
+// list, add, or remove cards owned by given chat with given command argument
+func manageOwnedCards(chatID int64, arg string, language a.Lang) string {
+	usage := fmt.Sprintf(localized(language, messageOwnUsageEng, messageOwnUsageKor), commandOwn, commandOwn, commandOwn)
+
+	args := strings.Fields(arg)
+
+	// list owned cards
+	if len(args) <= 0 {
+		owned := ownedCardsOf(chatID)
+		if len(owned) <= 0 {
+			return fmt.Sprintf(localized(language, messageOwnedNoneEng, messageOwnedNoneKor), commandOwn)
+		}
+
+		lines := []string{}
+		for _, item := range getItems(language) {
+			if count, exists := owned[item.HashName]; exists {
+				lines = append(lines, fmt.Sprintf("- %s%s: %d", rarityEmoji(rarityOf(item, language)), escapeMarkdown(item.Name), count))
+			}
+		}
+		sort.Strings(lines)
+
+		return fmt.Sprintf(localized(language, messageOwnedEng, messageOwnedKor), strings.Join(lines, "\n"))
+	}
+
+	var sign int
+	switch strings.ToLower(args[0]) {
+	case "add":
+		sign = 1
+	case "remove":
+		sign = -1
+	default:
+		return usage
+	}
+
+	// number of cards (default: 1)
+	count := 1
+	names := args[1:]
+	if len(names) > 1 {
+		if n, err := strconv.Atoi(names[len(names)-1]); err == nil {
+			if n <= 0 {
+				return usage
+			}
+			count, names = n, names[:len(names)-1]
+		}
+	}
+
+	query := sanitizeQuery(strings.Join(names, " "))
+	if query == "" {
+		return usage
+	}
+
+	item, _, found := resolveItem(query, language)
+	if !found {
+		return fmt.Sprintf(localized(language, messageSearchNoResultsEng, messageSearchNoResultsKor), escapeMarkdown(query))
+	}
+
+	owned := addOwnedCards(chatID, item.HashName, sign*count)
+
+	if localizedLanguageOf(language) == a.LangKorean {
+		return fmt.Sprintf(messageOwnedCountKor, escapeMarkdown(item.Name), owned)
+	}
+	return fmt.Sprintf(messageOwnedCountEng, owned, escapeMarkdown(item.Name))
+}
+
+// get max number of items in a listed message
+func maxNumListedItems() int {
+	if max := conf().MaxListedItems; max > 0 {
+		return max
+	}
+
+	return defaultMaxNumListedItems
+}
+
+// clamp given number of items requested by user to `maxNumListedItems`
+func clampNumListedItems(requested int) (n int, clamped bool) {
+	if max := maxNumListedItems(); requested > max {
+		return max, true
+	}
+
+	return requested, false
+}
+
+// list given items, up to `maxNumListedItems`
+func listItems(items []a.MarketItem, language a.Lang) string {
+	lines := []string{}
+
+	for i, item := range items {
+		if i >= maxNumListedItems() {
+			lines = append(lines, fmt.Sprintf(localized(language, messageListMoreEng, messageListMoreKor), len(items)-i))
+			break
+		}
+
+		lines = append(lines, itemLine(item, language))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// format given items as lines of a list
+func itemLines(items []a.MarketItem, language a.Lang) []string {
+	lines := []string{}
+	for _, item := range items {
+		lines = append(lines, itemLine(item, language))
+	}
+
+	return lines
+}
+
+// format given item as a line of a list
+func itemLine(item a.MarketItem, language a.Lang) string {
+	return fmt.Sprintf("- %s%s: *%s*", rarityEmoji(rarityOf(item, language)), escapeMarkdown(item.Name), item.SellPriceText)
+}
+
+// parse number of listed items from given command argument
+//
+// (returns default number for empty argument, and whether it was clamped)
+func parseNumListedItems(arg string) (n int, clamped bool, err error) {
+	if arg == "" {
+		return defaultNumListedItems, false, nil
+	}
+
+	if n, err = strconv.Atoi(arg); err != nil {
+		return 0, false, err
+	}
+	if n <= 0 {
+		return 0, false, fmt.Errorf("not a positive number: %d", n)
+	}
+
+	n, clamped = clampNumListedItems(n)
+
+	return n, clamped, nil
+}
+
+// get cards which cost the most to complete the collection (price * number of needed cards)
+func getCompletionCosts(arg string, chatID int64, language a.Lang) string {
+	n, clamped, err := parseNumListedItems(arg)
+	if err != nil {
+		return fmt.Sprintf(localized(language, messageCompletionUsageEng, messageCompletionUsageKor), commandCompletion, commandCompletion)
+	}
+
+	items := append([]a.MarketItem{}, getItems(language)...)
+	costOf := func(item a.MarketItem) int {
+		return item.SellPrice * numCardsOf(item, language, collectionModePlayset)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if costOf(items[i]) == costOf(items[j]) {
+			return items[i].Name < items[j].Name
+		}
+		return costOf(items[i]) > costOf(items[j])
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+
+	currencies := currenciesOf(chatID)
+	lines := []string{}
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("- %s%s: *%s* (%d × %s)",
+			rarityEmoji(rarityOf(item, language)),
+			escapeMarkdown(item.Name),
+			formatPrices(float32(costOf(item))/100.0, currencies),
+			numCardsOf(item, language, collectionModePlayset),
+			item.SellPriceText,
+		))
+	}
+
+	note := ""
+	if clamped {
+		note = fmt.Sprintf(localized(language, messageListClampedEng, messageListClampedKor), n)
+	}
+
+	return fmt.Sprintf(localized(language, messageCompletionCostEng, messageCompletionCostKor), len(items), strings.Join(lines, "\n"), note)
+}
+
+// get message of the most expensive items with given command argument
+func getTop(arg string, language a.Lang) (string, *t.InlineKeyboardMarkup, []a.MarketItem) {
+	return getRankedItems(arg, commandTop, false, language)
+}
+
+// get message of the least expensive items with given command argument
+//
+// (items without sell orders are skipped)
+func getCheapest(arg string, language a.Lang) (string, *t.InlineKeyboardMarkup, []a.MarketItem) {
+	return getRankedItems(arg, commandCheapest, true, language)
+}
+
+// get message of items ranked by their prices, with the listed items
+//
+// (items of the same price are ordered by their names,
+// and paginated with inline buttons when there are more than `max_listed_items` in config)
+func getRankedItems(arg, command string, ascending bool, language a.Lang) (string, *t.InlineKeyboardMarkup, []a.MarketItem) {
+	n := defaultNumTopItems
+	if arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n <= 0 {
+			return fmt.Sprintf(localized(language, messageRankedUsageEng, messageRankedUsageKor), command, command), nil, nil
+		}
+	}
+
+	items := []a.MarketItem{}
+	for _, item := range getItems(language) {
+		if ascending && item.SellPrice <= 0 {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].SellPrice == items[j].SellPrice {
+			return items[i].Name < items[j].Name
+		}
+		if ascending {
+			return items[i].SellPrice < items[j].SellPrice
+		}
+		return items[i].SellPrice > items[j].SellPrice
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+
+	lines := []string{}
+	for i, item := range items {
+		lines = append(lines, fmt.Sprintf("%d. %s%s (%s): *%s*",
+			i+1,
+			rarityEmoji(rarityOf(item, language)),
+			escapeMarkdown(item.Name),
+			escapeMarkdown(item.AssetDescription.Type),
+			item.SellPriceText,
+		))
+	}
+
+	format := localized(language, messageTopEng, messageTopKor)
+	if ascending {
+		format = localized(language, messageCheapestEng, messageCheapestKor)
+	}
+	message, markup := paginate(fmt.Sprintf(format, len(items), "%s"), lines, language)
+
+	return message, markup, items
+}
+
+// get items of given rarity priced at or below given price (in cents), sorted by price
+func affordableItems(rarity a.Rarity, maxPrice int, language a.Lang) []a.MarketItem {
+	results := []a.MarketItem{}
+
+	for _, item := range getItems(language) {
+		if item.SellPrice > 0 && item.SellPrice <= maxPrice && rarityOf(item, language) == rarity {
+			results = append(results, item)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].SellPrice == results[j].SellPrice {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].SellPrice < results[j].SellPrice
+	})
+
+	return results
+}
+
+// get message of affordable items with given command argument
+//
+// (paginated with inline buttons when there are too many items)
+func getAffordable(arg string, language a.Lang) (string, *t.InlineKeyboardMarkup) {
+	usage := fmt.Sprintf(localized(language, messageAffordableUsageEng, messageAffordableUsageKor), commandAffordable, commandAffordable)
+
+	args := strings.Fields(arg)
+	if len(args) != 2 {
+		return usage, nil
+	}
+
+	rarity, exists := _rarityKeywords[strings.ToLower(args[0])]
+	if !exists {
+		return usage, nil
+	}
+
+	maxPrice, err := parsePrice(args[1])
+	if err != nil || maxPrice <= 0 {
+		return usage, nil
+	}
+
+	items := affordableItems(rarity, maxPrice, language)
+	rarityName := _localizedRarities[language][rarity]
+	dollars := float32(maxPrice) / 100.0
+
+	if len(items) <= 0 {
+		if localizedLanguageOf(language) == a.LangKorean {
+			return fmt.Sprintf(messageAffordableNoneKor, dollars, rarityName), nil
+		}
+		return fmt.Sprintf(messageAffordableNoneEng, rarityName, dollars), nil
+	}
+
+	header := fmt.Sprintf(localized(language, messageAffordableEng, messageAffordableKor), rarityName, dollars, "%s")
+
+	return paginate(header, itemLines(items, language), language)
+}
+
+// get cards which look like heroes but are missing in `_localizedHeroes`
+//
+// (heuristically judged with `_localizedHeroTypePatterns` and `_localizedHeroNamePatterns`)
+func getSuspectHeroes(language a.Lang) string {
+	lines := []string{}
+
+	for _, item := range getItems(language) {
+		if isHero(item.Name, language) || isBasicHero(item.Name, language) {
+			continue
+		}
+
+		reasons := []string{}
+		if pattern, exists := _localizedHeroTypePatterns[language]; exists && pattern.MatchString(item.AssetDescription.Type) {
+			reasons = append(reasons, "type")
+		}
+		if pattern, exists := _localizedHeroNamePatterns[language]; exists && pattern.MatchString(item.Name) {
+			reasons = append(reasons, "name")
+		}
+
+		if len(reasons) > 0 {
+			lines = append(lines, fmt.Sprintf("- %s (%s) - by %s", escapeMarkdown(item.Name), escapeMarkdown(item.AssetDescription.Type), strings.Join(reasons, ", ")))
+		}
+	}
+
+	if len(lines) <= 0 {
+		return fmt.Sprintf(messageSuspectHeroesNone, language)
+	}
+
+	return fmt.Sprintf(messageSuspectHeroes, language, strings.Join(lines, "\n"))
+}
+
+// get raw json of a card with given name, for debugging
+func getRaw(arg string, language a.Lang) string {
+	query := sanitizeQuery(arg)
+	if query == "" {
+		return fmt.Sprintf(messageRawUsage, commandRaw)
+	}
+
+	item, numMatches, found := resolveItem(query, language)
+	if !found {
+		return fmt.Sprintf(messageRawNotFound, escapeMarkdown(query))
+	}
+
+	bytes, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Sprintf(messageRawError, err)
+	}
+
+	raw := string(bytes)
+	if len(raw) > maxRawLength {
+		cut := maxRawLength
+		for cut > 0 && !utf8.RuneStart(raw[cut]) {
+			cut--
+		}
+		raw = raw[:cut] + messageRawTruncated
+	}
+
+	return fmt.Sprintf(messageRaw, escapeMarkdown(item.Name), numMatches, raw)
+}
+
+// resolve a single card with given name
+//
+// (when multiple cards match without an exact one, returns a message listing them instead)
+func resolveSingleItem(query string, language a.Lang) (item a.MarketItem, message string) {
+	items := searchItemsByName(query, language)
+	if len(items) <= 0 {
+		return item, fmt.Sprintf(localized(language, messageSearchNoResultsEng, messageSearchNoResultsKor), escapeMarkdown(query))
+	}
+
+	if item, found := singleItemOf(items, query); found {
+		return item, ""
+	}
+
+	if localizedLanguageOf(language) == a.LangKorean {
+		return item, fmt.Sprintf(messageAmbiguousKor, escapeMarkdown(query), len(items), listItems(items, language))
+	}
+	return item, fmt.Sprintf(messageAmbiguousEng, len(items), escapeMarkdown(query), listItems(items, language))
+}
+
+// get a card with exactly the same name as given query from given search results, or the only one of them
+func singleItemOf(items []a.MarketItem, query string) (item a.MarketItem, found bool) {
+	for _, i := range items {
+		if strings.EqualFold(i.Name, query) {
+			return i, true
+		}
+	}
+	if len(items) == 1 {
+		return items[0], true
+	}
+
+	return item, false
+}
+
+// get detailed message of a card with given name, with its recent price trend
+//
+// (the card is returned only when a single card is resolved)
+func getCard(chatID int64, arg string, language a.Lang) (string, *a.MarketItem) {
+	query := sanitizeQuery(arg)
+	if query == "" {
+		return fmt.Sprintf(localized(language, messageCardUsageEng, messageCardUsageKor), commandCard, commandCard), nil
+	}
+
+	item, message := resolveSingleItem(query, language)
+	if message != "" {
+		return message, nil
+	}
+
+	message = detailedMessageOf(chatID, item, language, true)
+	if sparkline := sparklineOf(recentCardPrices(item.HashName, numSparklinePoints)); sparkline != "" {
+		message += "\n" + fmt.Sprintf(localized(language, messageCardTrendEng, messageCardTrendKor), sparkline)
+	}
+
+	return message, &item
+}
+
+// send the image of given card with given message as its caption
+//
+// (returns given message back when images are disabled or failed to be sent, so that it can be sent as text)
+func sendCardImage(b messageSender, chatID int64, item a.MarketItem, message string) string {
+	iconURL := item.AssetDescription.IconURL()
+	if !conf().SendImages || iconURL == "" || len([]rune(message)) > maxCaptionLength {
+		return message
+	}
+
+	if err := sendHandlingErrors(chatID, func() t.APIResponseBase {
+		return b.SendPhoto(chatID, t.InputFileFromURL(iconURL), t.OptionsSendPhoto{}.
+			SetCaption(formatMessage(message)).
+			SetParseMode(parseMode()).
+			SetReplyMarkup(replyKeyboardMarkup())).APIResponseBase
+	}); err != nil {
+		logErrorf("Failed to send image of card: %s", err)
+
+		return message
+	}
+
+	return ""
+}
+
+// resolve a card with given name
+//
+// (prefers a card with exactly the same name, then the first one of search results)
+func resolveItem(query string, language a.Lang) (item a.MarketItem, numMatches int, found bool) {
+	items := searchItemsByName(query, language)
+	if len(items) <= 0 {
+		return item, 0, false
+	}
+
+	item = items[0]
+	for _, i := range items {
+		if strings.EqualFold(i.Name, query) {
+			item = i
+			break
+		}
+	}
+
+	return item, len(items), true
+}
+
+// get message of items priced close to the price in given command argument
+//
+// (paginated with inline buttons when there are too many items)
+func getNearby(arg string, language a.Lang) (string, *t.InlineKeyboardMarkup) {
+	usage := fmt.Sprintf(localized(language, messageNearbyUsageEng, messageNearbyUsageKor), commandNearby, commandNearby)
+
+	args := strings.Fields(arg)
+	if len(args) < 1 || len(args) > 2 {
+		return usage, nil
+	}
+
+	target, err := parsePrice(args[0])
+	if err != nil || target <= 0 {
+		return usage, nil
+	}
+	tolerance := target / 10 // default: 10%
+	if len(args) == 2 {
+		if tolerance, err = parsePrice(args[1]); err != nil || tolerance < 0 {
+			return usage, nil
+		}
+	}
+
+	distanceOf := func(item a.MarketItem) int {
+		if item.SellPrice > target {
+			return item.SellPrice - target
+		}
+		return target - item.SellPrice
+	}
+
+	items := []a.MarketItem{}
+	for _, item := range getItems(language) {
+		if item.SellPrice > 0 && distanceOf(item) <= tolerance {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if distanceOf(items[i]) == distanceOf(items[j]) {
+			return items[i].Name < items[j].Name
+		}
+		return distanceOf(items[i]) < distanceOf(items[j])
+	})
+
+	dollars, toleranceDollars := float32(target)/100.0, float32(tolerance)/100.0
+	if len(items) <= 0 {
+		if localizedLanguageOf(language) == a.LangKorean {
+			return fmt.Sprintf(messageNearbyNoneKor, dollars, toleranceDollars), nil
+		}
+		return fmt.Sprintf(messageNearbyNoneEng, toleranceDollars, dollars), nil
+	}
+
+	header := fmt.Sprintf(messageNearbyEng, toleranceDollars, dollars, "%s")
+	if localizedLanguageOf(language) == a.LangKorean {
+		header = fmt.Sprintf(messageNearbyKor, dollars, toleranceDollars, "%s")
+	}
+
+	return paginate(header, itemLines(items, language), language)
+}
+
+// parse given price text in dollars (eg. "3", "$2.50") into cents
+func parsePrice(txt string) (int, error) {
+	dollars, err := strconv.ParseFloat(strings.TrimPrefix(txt, "$"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(dollars) || math.IsInf(dollars, 0) || math.Abs(dollars) > math.MaxInt32/100 {
+		return 0, fmt.Errorf("not a valid price: %s", txt)
+	}
+
+	return int(math.Round(dollars * 100)), nil
+}
+
+// set currencies of given chat with given command argument
+func setCurrenciesWith(chatID int64, arg string, language a.Lang) string {
+	if arg == "" {
+		return fmt.Sprintf(localized(language, messageSetCurrencyUsageEng, messageSetCurrencyUsageKor), commandSetCurrency, maxNumCurrencies, commandSetCurrency)
+	}
+
+	currencies, err := parseCurrencies(arg)
+	if err == errExchangeRatesUnavailable {
+		return localized(language, messageNoExchangeRatesEng, messageNoExchangeRatesKor)
+	} else if err != nil {
+		return fmt.Sprintf(localized(language, messageSetCurrencyErrorEng, messageSetCurrencyErrorKor), err)
+	}
+
+	setCurrencies(chatID, currencies)
+
+	return fmt.Sprintf(localized(language, messageSetCurrencyEng, messageSetCurrencyKor), strings.Join(currencies, ", "))
+}
+
+// get tax/fee of an amount of money with given command argument
+func getTax(arg string, chatID int64, language a.Lang) string {
+	price, err := parsePrice(arg)
+	if err != nil || price < 0 {
+		return fmt.Sprintf(localized(language, messageTaxUsageEng, messageTaxUsageKor), commandTax, commandTax)
+	}
+
+	currencies := currenciesOf(chatID)
+	dollars := float32(price) / 100.0
+	tax := taxOf(dollars)
+
+	return fmt.Sprintf(localized(language, messageTaxEng, messageTaxKor),
+		formatPrices(dollars, currencies),
+		formatPrices(tax, currencies),
+		formatPrices(dollars+tax, currencies),
+	)
+}
+
+// get conversion of money with given command argument
+func getConversion(arg string, language a.Lang) string {
+	usage := fmt.Sprintf(localized(language, messageConvertUsageEng, messageConvertUsageKor), commandConvert, commandConvert)
+
+	args := strings.Fields(arg)
+	if len(args) != 3 {
+		return usage
+	}
+
+	amount, err := strconv.ParseFloat(args[0], 32)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) || amount < 0 {
+		return usage
+	}
+	from, to := strings.ToUpper(args[1]), strings.ToUpper(args[2])
+
+	converted, err := convertMoney(float32(amount), from, to)
+	if err == errExchangeRatesUnavailable {
+		return localized(language, messageNoExchangeRatesEng, messageNoExchangeRatesKor)
+	} else if err != nil {
+		return fmt.Sprintf(localized(language, messageConvertErrorEng, messageConvertErrorKor), err)
+	}
+
+	return fmt.Sprintf(localized(language, messageConvertEng, messageConvertKor),
+		formatMoney(float32(amount), from),
+		formatMoney(converted, to),
+	)
+}
+
+// send current market data as a file in the format of given command argument
+//
+// (returns a message only when the file could not be sent)
+func sendExport(b messageSender, chatID int64, arg string, language a.Lang) string {
+	format := exportFormat(strings.ToLower(arg))
+	if format == "" {
+		format = exportFormatJSON
+	}
+
+	switch format {
+	case exportFormatJSON, exportFormatJSONLines, exportFormatCSV:
+		exported, err := exportItems(getItems(language), format)
+		if err != nil {
+			return fmt.Sprintf(localized(language, messageExportErrorEng, messageExportErrorKor), err)
+		}
+
+		if err := sendHandlingErrors(chatID, func() t.APIResponseBase {
+			return b.SendDocument(chatID, t.InputFileFromBytes(exported), t.OptionsSendDocument{}.
+				SetCaption(exportFilename(language, format))).APIResponseBase
+		}); err != nil {
+			logErrorf("Failed to send exported file: %s", err)
+
+			return fmt.Sprintf(localized(language, messageExportErrorEng, messageExportErrorKor), err)
+		}
+
+		return ""
+	}
+
+	return fmt.Sprintf(localized(language, messageExportUsageEng, messageExportUsageKor), commandExport)
+}
+
+// send help message as an image, falling back to text on failure
+func sendHelpImage(b messageSender, chatID int64, language a.Lang) string {
+	help := getHelp(language)
+
+	if err := sendTextImage(b, chatID, help); err != nil {
+		logErrorf("Failed to send help image: %s", err)
+
+		return help
+	}
+
+	return ""
+}
+
+// render given text as an image and send it
+func sendTextImage(b messageSender, chatID int64, text string) error {
+	img, err := renderTextImage(text)
+	if err != nil {
+		return err
+	}
+
+	if err := sendHandlingErrors(chatID, func() t.APIResponseBase {
+		return b.SendPhoto(chatID, t.InputFileFromBytes(img), t.OptionsSendPhoto{}).APIResponseBase
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// split given message into ones which are not longer than `maxMessageLength` (by lines)
+func splitMessage(message string) []string {
+	messages := []string{}
+
+	current := []rune{}
+	for _, line := range strings.SplitAfter(message, "\n") {
+		runes := []rune(line)
+
+		if len(current)+len(runes) > maxMessageLength && len(current) > 0 {
+			messages = append(messages, string(current))
+			current = []rune{}
+		}
+
+		// split a line which is too long by itself
+		for len(runes) > maxMessageLength {
+			messages = append(messages, string(runes[:maxMessageLength]))
+			runes = runes[maxMessageLength:]
+		}
+
+		current = append(current, runes...)
+	}
+	if len(current) > 0 {
+		messages = append(messages, string(current))
+	}
+
+	return messages
+}
+
+// send a chart of full collection's price over the window in given command argument
+func sendTrendChart(b messageSender, chatID int64, arg string, language a.Lang) string {
+	window, err := parseTrendWindow(arg)
+	if err != nil {
+		return fmt.Sprintf(localized(language, messageTrendChartUsageEng, messageTrendChartUsageKor), commandTrendChart, commandTrendChart, commandTrendChart)
+	}
+	windowText := strings.TrimSpace(arg)
+	if windowText == "" {
+		windowText = "7d"
+	}
+
+	entries := historySince(time.Now().Add(-window))
+	if len(entries) < minNumTrendEntries {
+		return fmt.Sprintf(localized(language, messageTrendChartNoHistoryEng, messageTrendChartNoHistoryKor), windowText)
+	}
+
+	title := fmt.Sprintf(localized(language, messageTrendChartTitleEng, messageTrendChartTitleKor), windowText)
+	chart, err := renderTrendChart(entries, title, language)
+	if err != nil {
+		logErrorf("Failed to render trend chart: %s", err)
+
+		return fmt.Sprintf(localized(language, messageTrendChartErrorEng, messageTrendChartErrorKor), err)
+	}
+
+	if err := sendHandlingErrors(chatID, func() t.APIResponseBase {
+		return b.SendPhoto(chatID, t.InputFileFromBytes(chart), t.OptionsSendPhoto{}.
+			SetCaption(title)).APIResponseBase
+	}); err != nil {
+		logErrorf("Failed to send trend chart: %s", err)
+
+		return fmt.Sprintf(localized(language, messageTrendChartErrorEng, messageTrendChartErrorKor), err)
+	}
+
+	return ""
+}
+
+// set default action of given chat with given command argument
+func setDefaultActionWith(chatID int64, arg string, language a.Lang) string {
+	action := defaultAction(strings.ToLower(arg))
+
+	switch action {
+	case defaultActionNone, defaultActionSearch, defaultActionSummarize:
+		setDefaultAction(chatID, action)
+
+		return fmt.Sprintf(localized(language, messageSetDefaultEng, messageSetDefaultKor), action)
+	}
+
+	return fmt.Sprintf(localized(language, messageSetDefaultUsageEng, messageSetDefaultUsageKor), commandDefault)
+}
+
+// set language of given chat with given command argument
+//
+// (returns the result message in the newly set language)
+func setChatLanguageWith(chatID int64, arg string, language a.Lang, userLanguage a.Lang) string {
+	code := strings.ToLower(strings.TrimSpace(arg))
+
+	if code == "auto" {
+		setChatLanguage(chatID, nil)
+
+		return localized(userLanguage, messageSetLangAutoEng, messageSetLangAutoKor)
+	}
+
+	if lang, exists := langFromCode(code); exists {
+		setChatLanguage(chatID, &lang)
+
+		return fmt.Sprintf(localized(lang, messageSetLangEng, messageSetLangKor), code)
+	}
+
+	codes := []string{}
+	for _, c := range _languageCodes {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+
+	return fmt.Sprintf(localized(language, messageSetLangUsageEng, messageSetLangUsageKor), commandLang, strings.Join(codes, "|"))
+}
+
+// get results of searching cards with given text
+//
+// (paginated with inline buttons when there are too many results)
+func getSearchResults(txt string, language a.Lang) (string, *t.InlineKeyboardMarkup) {
+	query := sanitizeQuery(txt)
+
+	items := searchItemsByName(query, language)
+	if len(items) <= 0 {
+		return fmt.Sprintf(localized(language, messageSearchNoResultsEng, messageSearchNoResultsKor), escapeMarkdown(query)), nil
+	}
+
+	lines := itemLines(items, language)
+
+	// (header is used as a format string again, so '%' in the query should be escaped)
+	header := fmt.Sprintf(localized(language, messageSearchResultsEng, messageSearchResultsKor), strings.Replace(escapeMarkdown(query), "%", "%%", -1), "%s")
+
+	return paginate(header, lines, language)
+}
+
+// get settings of given chat
+func getMySettings(chatID int64, language a.Lang) string {
+	currencies := fmt.Sprintf(localized(language, messageDefaultCurrencyEng, messageDefaultCurrencyKor), defaultCurrency())
+	if selected := currenciesOf(chatID); len(selected) > 0 {
+		currencies = strings.Join(selected, ", ")
+	}
+
+	lang := "auto"
+	if chatLanguage, exists := chatLanguageOf(chatID); exists {
+		lang = _languageCodes[chatLanguage]
+	}
+
+	notifications := localized(language, messageOffEng, messageOffKor)
+	if isSubscribedToNewTypes(chatID) {
+		notifications = localized(language, messageOnEng, messageOnKor)
+	}
+
+	dailySummary := localized(language, messageOffEng, messageOffKor)
+	if isSubscribedToDailySummary(chatID) {
+		dailySummary = localized(language, messageOnEng, messageOnKor)
+	}
+
+	numOwned := 0
+	for _, count := range ownedCardsOf(chatID) {
+		numOwned += count
+	}
+
+	return fmt.Sprintf(localized(language, messageMySettingsEng, messageMySettingsKor),
+		currencies, defaultActionOf(chatID), lang, notifications,
+		dailySummary, numOwned, len(priceWatchesOf(chatID)),
+	)
+}
+
+// list all commands with their descriptions
+func getCommands(language a.Lang) string {
+	descriptions := _localizedCommandDescriptions[localizedLanguageOf(language)]
+
+	lines := []string{}
+	for _, command := range _commands {
+		if description, exists := descriptions[command]; exists {
+			lines = append(lines, fmt.Sprintf("%s - %s", command, description))
+		} else {
+			lines = append(lines, command)
+		}
+	}
+
+	return fmt.Sprintf(localized(language, messageCommandsEng, messageCommandsKor), strings.Join(lines, "\n"))
+}
+
+// get assumptions used for calculating prices
+func getAssumptions(language a.Lang) string {
+	basics := localized(language, messageCountedEng, messageCountedKor)
+	if conf().ExcludeBasicCards {
+		basics = localized(language, messageExcludedEng, messageExcludedKor)
+	}
+
+	return fmt.Sprintf(localized(language, messageAssumptionsEng, messageAssumptionsKor),
+		taxRate()*100,
+		maxNumCardsPerDeck, maxNumHeroCardsPerDeck,
+		basics,
+		cacheMinutes(),
+	)
+}
+
+// sanitize given search query: strip control characters, collapse whitespaces, and cap its length
+func sanitizeQuery(query string) string {
+	query = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, query)
+	query = strings.Join(strings.Fields(query), " ")
+
+	if runes := []rune(query); len(runes) > maxQueryLength {
+		query = strings.TrimSpace(string(runes[:maxQueryLength]))
+	}
+
+	return query
+}
+
+// search items by name (ignore case)
+//
+// (given name should be sanitized with `sanitizeQuery` beforehand)
+//
+// in Korean, a name of initial consonants only (eg. "ㄷㄲㅈㅅ") is matched against initial consonants of items' names
+//
+// when nothing matches, falls back to typo-tolerant matching (eg. "bristlback" => "Bristleback")
+func searchItemsByName(name string, language a.Lang) []a.MarketItem {
+	results := []a.MarketItem{}
+
+	byInitials := language == a.LangKorean && isHangulInitialsQuery(name)
+
+	items := getItems(language)
+	for _, item := range items {
+		if byInitials {
+			if matchesHangulInitials(item.Name, name) {
+				results = append(results, item)
+			}
+		} else if strings.Contains(strings.ToLower(item.Name), strings.ToLower(name)) {
+			results = append(results, item)
+		}
+	}
+
+	// fallback to typo-tolerant matching when nothing matched
+	if len(results) <= 0 && !byInitials {
+		results = fuzzySearchItems(items, name)
+	}
+
+	return results
+}
+
+// parse a trailing price filter in USD (eg. "<5", ">=10") of given search query
+//
+// (returns the query without the filter, or the query as it is with nil filter when there is no valid one;
+// cards without sell orders never match upper bounds)
+func parsePriceFilter(query string) (string, func(price int) bool) {
+	fields := strings.Fields(query)
+	if len(fields) <= 0 {
+		return query, nil
+	}
+
+	token := fields[len(fields)-1]
+	for _, op := range []string{"<=", ">=", "<", ">"} { // (longer operators first)
+		if !strings.HasPrefix(token, op) {
+			continue
+		}
+
+		limit, err := parsePrice(strings.TrimPrefix(token, op))
+		if err != nil || limit < 0 {
+			return query, nil
+		}
+
+		name := strings.Join(fields[:len(fields)-1], " ")
+		switch op {
+		case "<=":
+			return name, func(price int) bool { return price > 0 && price <= limit }
+		case ">=":
+			return name, func(price int) bool { return price >= limit }
+		case "<":
+			return name, func(price int) bool { return price > 0 && price < limit }
+		default:
+			return name, func(price int) bool { return price > limit }
+		}
+	}
+
+	return query, nil
+}
+
+// get language of given language code
+func langFromCode(code string) (a.Lang, bool) {
+	for language, c := range _languageCodes {
+		if c == code {
+			return language, true
+		}
+	}
+
+	return a.LangEnglish, false
+}
+
+// get id of the sender of given message (chat id when there is no sender)
+func senderIDOf(message *t.Message) int64 {
+	if message.From != nil {
+		return int64(message.From.ID)
+	}
+
+	return message.Chat.ID
+}
+
+// check language from given Telegram user
+//
+// (`u` can be nil for messages without a sender, eg. from anonymous group admins)
+func langFromUser(u *t.User) a.Lang {
+	if u != nil {
+		langCode := u.LanguageCode
+
+		if langCode != nil {
+			if strings.HasPrefix(*langCode, "ko") {
+				return a.LangKorean
+			}
+			// TODO - add more languages here
+		}
+	}
+
+	return a.LangEnglish // default
+}
+
+// get language for given chat (overridden with `commandLang`), or of given user
+func langForChat(chatID int64, u *t.User) a.Lang {
+	if language, exists := chatLanguageOf(chatID); exists {
+		return language
+	}
+
+	return langFromUser(u)
+}
+
+// check if a card with given name is a hero
+func isHero(name string, language a.Lang) bool {
+	for _, l := range fallbackChainOf(language) {
+		if heroes, exists := _heroSets[l]; exists {
+			_, exists = heroes[name]
+
+			return exists
+		}
+	}
+
+	logWarnf("* No heroes defined for language: %s", language)
+
+	return false
+}
+
+// merge given built-in heroes with the ones in config (language code => names)
+//
+// (configured names replace the built-in ones of the same language)
+func heroesWithConfigured(builtIn map[a.Lang][]string, configured map[string][]string) map[a.Lang][]string {
+	merged := map[a.Lang][]string{}
+	for language, heroes := range builtIn {
+		merged[language] = heroes
+	}
+
+	for code, heroes := range configured {
+		if language, exists := langFromCode(code); exists {
+			merged[language] = heroes
+		}
+	}
+
+	return merged
+}
+
+// set heroes and basic heroes (with `basicHeroSuffix`) of each language from given lists of heroes
+func setHeroes(heroes map[a.Lang][]string) {
+	_localizedHeroes = map[a.Lang][]string{}
+	_localizedBasicHeroes = map[a.Lang][]string{}
+
+	for language, names := range heroes {
+		_localizedHeroes[language] = []string{}
+		_localizedBasicHeroes[language] = []string{}
+
+		for _, name := range names {
+			if strings.HasSuffix(name, basicHeroSuffix) {
+				name = strings.TrimSuffix(name, basicHeroSuffix)
+				_localizedBasicHeroes[language] = append(_localizedBasicHeroes[language], name)
+			}
+			_localizedHeroes[language] = append(_localizedHeroes[language], name)
+		}
+	}
+
+	_heroSets = heroSetsFrom(_localizedHeroes)
+	_basicHeroSets = heroSetsFrom(_localizedBasicHeroes)
+}
+
+// get names of heroes in given language (following its fallback chain)
+func heroesOf(language a.Lang) []string {
+	for _, l := range fallbackChainOf(language) {
+		if heroes, exists := _localizedHeroes[l]; exists {
+			return heroes
+		}
+	}
+
+	return nil
+}
+
+// check if a card with given name is a basic hero (which is given for free)
+func isBasicHero(name string, language a.Lang) bool {
+	_, exists := _basicHeroSets[language][name]
+
+	return exists
+}
+
+// build sets of heroes from given lists of heroes
+func heroSetsFrom(heroes map[a.Lang][]string) map[a.Lang]map[string]struct{} {
+	sets := map[a.Lang]map[string]struct{}{}
+
+	for language, names := range heroes {
+		sets[language] = map[string]struct{}{}
+
+		for _, name := range names {
+			sets[language][name] = struct{}{}
+		}
+	}
+
+	return sets
+}
+
+// get number of cards needed for given item in given collection mode
+func numCardsOf(item a.MarketItem, language a.Lang, mode collectionMode) int {
+	if mode == collectionModeSingles {
+		return 1
+	}
+
+	if isHero(item.Name, language) {
+		return maxNumHeroCardsPerDeck
+	}
+
+	return maxNumCardsPerDeck
+}
+
+// get collection mode from given command argument
+func collectionModeFrom(arg string) collectionMode {
+	if collectionMode(strings.ToLower(arg)) == collectionModeSingles {
+		return collectionModeSingles
+	}
+
+	return collectionModePlayset // default
+}
+
+// get collection mode and rarity filter from given argument of `commandSummarize` (eg. "rare singles")
+//
+// (unrecognized words are ignored, so it falls back to the full summary)
+func summaryOptionsFrom(arg string, language a.Lang) (mode collectionMode, rarity a.Rarity) {
+	mode, rarity = collectionModePlayset, a.RarityAll
+
+	for _, word := range strings.Fields(arg) {
+		if m := collectionModeFrom(word); m == collectionModeSingles {
+			mode = m
+		} else if r, exists := rarityFromKeyword(word, language); exists {
+			rarity = r
+		}
+	}
+
+	return mode, rarity
+}
+
+// get rarity from given keyword (eg. "rare", "희귀", or localized rarity names like "Rare Card")
+func rarityFromKeyword(keyword string, language a.Lang) (a.Rarity, bool) {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+
+	if rarity, exists := _rarityKeywords[keyword]; exists {
+		return rarity, true
+	}
+
+	for rarity, name := range _localizedRarities[localizedLanguageOf(language)] {
+		if words := strings.Fields(name); strings.EqualFold(name, keyword) || (len(words) > 0 && strings.EqualFold(words[0], keyword)) {
+			return rarity, true
+		}
+	}
+
+	return a.RarityAll, false
+}
+
+// get rarity of given item
+func rarityOf(item a.MarketItem, language a.Lang) a.Rarity {
+	for _, l := range fallbackChainOf(language) {
+		if rarity, exists := _raritiesOfTypes[l][item.AssetDescription.Type]; exists {
+			return rarity
+		}
+	}
+
+	return a.RarityAll // unknown rarity
+}
+
+// build reverse lookup maps (item type => rarity) from given localized rarities
+func raritiesOfTypesFrom(rarities map[a.Lang]map[a.Rarity]string) map[a.Lang]map[string]a.Rarity {
+	reversed := map[a.Lang]map[string]a.Rarity{}
+
+	for language, types := range rarities {
+		reversed[language] = map[string]a.Rarity{}
+
+		for rarity, itemType := range types {
+			reversed[language][itemType] = rarity
+		}
+	}
+
+	return reversed
+}
+
+// get configured emoji (with a trailing space) for given rarity
+//
+// (when keywords of the same rarity are configured, the first one in sorted order is used)
+func rarityEmoji(rarity a.Rarity) string {
+	emojis := conf().RarityEmojis
+
+	keywords := []string{}
+	for keyword := range emojis {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		if r, exists := _rarityKeywords[keyword]; exists && r == rarity {
+			return emojis[keyword] + " "
+		}
+	}
+
+	return "" // none
+}
+
+// calculate tax of given price
+func taxOf(price float32) float32 {
+	return taxRate() * price
+}
+
+// get rate of tax/fee (`tax_rate` in config, or `defaultTaxRate`)
+func taxRate() float32 {
+	if rate := conf().TaxRate; rate != nil {
+		return *rate
+	}
+
+	return defaultTaxRate
+}
+
+// check if given chat id is one of admins
+func isAdmin(chatID int64) bool {
+	for _, id := range conf().AdminChatIDs {
+		if id == chatID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check if given item has an icon
+//
+// (icon url ends with '/' when the item has no icon path)
+func hasIcon(item a.MarketItem) bool {
+	url := item.AssetDescription.IconURL()
+
+	return len(url) > 0 && !strings.HasSuffix(url, "/")
+}
+
+// get data quality report of cached items
+func getReport() string {
+	_lock.RLock()
+	defer _lock.RUnlock()
+
+	var reports []string
+	for _, language := range _languages {
+		var numNoPrice, numNoIcon, numUnknownRarity int
+
+		items := _items[language]
+		for _, item := range items {
+			if item.SellPrice <= 0 {
+				numNoPrice++
+			}
+			if !hasIcon(item) {
+				numNoIcon++
+			}
+			if rarityOf(item, language) == a.RarityAll {
+				numUnknownRarity++
+			}
+		}
+
+		age := messageReportNeverUpdated
+		if updated, exists := _itemsUpdated[language]; exists {
+			age = time.Since(updated).Truncate(time.Second).String()
+		}
+
+		reports = append(reports, fmt.Sprintf(messageReportLanguage,
+			language,
+			len(items),
+			numNoPrice,
+			numNoIcon,
+			numUnknownRarity,
+			age,
+		))
+	}
+
+	return fmt.Sprintf(messageReport, strings.Join(reports, "\n"))
+}
+
+// get uptime, cache status, and statistics of the bot
+func getStats() string {
+	_lock.RLock()
+	var caches []string
+	for _, language := range _languages {
+		updated := messageReportNeverUpdated
+		if at, exists := _itemsUpdated[language]; exists {
+			updated = at.UTC().Format(timestampFormat)
+		}
+
+		caches = append(caches, fmt.Sprintf(messageStatsLanguage, language, len(_items[language]), updated))
+	}
+	_lock.RUnlock()
+
+	stats := currentStats()
+
+	return fmt.Sprintf(messageStats,
+		time.Since(_startTime).Truncate(time.Second).String(), _startTime.UTC().Format(timestampFormat),
+		cacheTTL().String(),
+		strings.Join(caches, "\n"),
+		stats.since.UTC().Format(timestampFormat),
+		stats.numMessages,
+		stats.numInlineQueries,
+		stats.numCacheHits,
+		stats.numCacheMisses,
+	)
+}
+
+// get message for unknown command in given chat type
+func getFallbackMessage(txt, chatType string, language a.Lang) string {
+	// configured one,
+	if messages, exists := conf().FallbackMessages[chatType]; exists {
+		if message, exists := messages[_languageCodes[language]]; exists {
+			return message
+		}
+	}
+
+	// or default
+	if len(txt) > 0 {
+		return fmt.Sprintf("%s: %s", escapeMarkdown(txt), messageUnknownCommand)
+	}
+	return messageUnknownCommand
+}
+
+// get one of given messages localized for given language
+//
+// (follows the fallback chain of given language)
+func localized(language a.Lang, eng, kor string) string {
+	if localizedLanguageOf(language) == a.LangKorean {
+		return kor
+	}
+
+	return eng // default = English
+}
+
+// get the first language in the fallback chain of given language which has localized messages
+func localizedLanguageOf(language a.Lang) a.Lang {
+	for _, l := range fallbackChainOf(language) {
+		switch l {
+		case a.LangKorean, a.LangEnglish:
+			return l
+		}
+	}
+
+	return a.LangEnglish
+}
+
+// get given language followed by its fallback languages, ending with English
+func fallbackChainOf(language a.Lang) []a.Lang {
+	chain := append([]a.Lang{language}, _languageFallbacks[language]...)
+	if chain[len(chain)-1] != a.LangEnglish {
+		chain = append(chain, a.LangEnglish)
+	}
+
+	return chain
+}
+
+// get command of given text
+//
+// (bot name is stripped from commands like "/summarize@some_bot")
+func commandOf(txt string) string {
+	if fields := strings.Fields(txt); len(fields) > 0 {
+		if strings.HasPrefix(fields[0], "/") {
+			return strings.SplitN(fields[0], "@", 2)[0]
+		}
+
+		return fields[0]
+	}
+
+	return ""
+}
+
+// get chat action for given command text
+//
+// (a command with its argument, eg. "/help image", is looked up before the command itself)
+func chatActionFor(txt string) t.ChatAction {
+	command := commandOf(txt)
+	if action, exists := _commandChatActions[strings.ToLower(strings.Join(strings.Fields(txt), " "))]; exists {
+		return action
+	}
+	if action, exists := _commandChatActions[command]; exists {
+		return action
+	}
+
+	return t.ChatActionTyping // default
+}
+
+// keep sending given chat action until the returned function is called
+func keepSendingChatAction(b messageSender, chatID int64, action t.ChatAction) (stop func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(chatActionIntervalSeconds * time.Second)
+		defer ticker.Stop()
+
+		for {
+			b.SendChatAction(chatID, action)
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// get argument of given command text
+func argumentOf(txt, command string) string {
+	return strings.TrimSpace(strings.TrimPrefix(txt, command))
+}
+
+// process incoming updates with this function
+func processUpdate(b messageSender, update t.Update) bool {
+	// process result
+	result := false
+
+	// text from message
+	var txt string
+	if update.Message.HasText() {
+		txt = *update.Message.Text
+	} else {
+		txt = ""
+	}
+
+	// messages without a sender fall back to the default language,
+	// and chat-specific features are keyed by chat id instead of the sender
+	chatID := update.Message.Chat.ID
+	language := langForChat(chatID, update.Message.From)
+
+	// the chat is reachable again
+	unmarkChatBlocked(chatID)
+
+	// ignore accidentally repeated commands
+	command := commandOf(txt)
+	if conf().SuppressDuplicateCommands && len(command) > 0 && isDuplicateCommand(chatID, txt) {
+		logDebugf("Ignoring duplicate command in chat #%d: %s", chatID, txt)
+		return false
+	}
+
+	var message string
+	var markup *t.InlineKeyboardMarkup // for paginated messages
+
+	// check cooldown of the command
+	if remaining := checkCooldown(command, senderIDOf(update.Message)); remaining > 0 {
+		message = fmt.Sprintf(localized(language, messageCooldownEng, messageCooldownKor), int(math.Ceil(remaining.Seconds())), command)
+	}
+
+	// 'typing...', 'sending photo...', etc. until the message is ready
+	// (only for handled texts, not for cooling down or falling back)
+	stopChatAction := func() {}
+	if len(message) <= 0 && isHandledText(txt, chatID) {
+		stopChatAction = keepSendingChatAction(b, chatID, chatActionFor(txt))
+	}
+	defer stopChatAction()
+
+	switch {
+	// cooling down
+	case len(message) > 0:
+	// start
+	case strings.HasPrefix(txt, commandStart):
+		message = getHelp(language)
+		// summarize
+	case strings.HasPrefix(txt, commandSummarize):
+		mode, rarity := summaryOptionsFrom(argumentOf(txt, commandSummarize), language)
+		message = checkSummary(chatID, language, mode, rarity)
+	// all-time lowest/highest prices
+	case strings.HasPrefix(txt, commandExtremes):
+		message = getExtremes(language, currenciesOf(chatID))
+	// chart of full collection's price
+	case strings.HasPrefix(txt, commandTrendChart):
+		message = sendTrendChart(b, chatID, argumentOf(txt, commandTrendChart), language)
+	// changes of cards' types
+	case strings.HasPrefix(txt, commandChanges):
+		message, markup = getTypeChanges(language)
+	// cards by cost to complete
+	case strings.HasPrefix(txt, commandCompletion):
+		message = getCompletionCosts(argumentOf(txt, commandCompletion), chatID, language)
+	// most expensive cards
+	case strings.HasPrefix(txt, commandTop):
+		var items []a.MarketItem
+		if message, markup, items = getTop(argumentOf(txt, commandTop), language); len(items) == 1 {
+			message = sendCardImage(b, chatID, items[0], message)
+		}
+	// least expensive cards
+	case strings.HasPrefix(txt, commandCheapest):
+		var items []a.MarketItem
+		if message, markup, items = getCheapest(argumentOf(txt, commandCheapest), language); len(items) == 1 {
+			message = sendCardImage(b, chatID, items[0], message)
+		}
+	// overview of heroes
+	case strings.HasPrefix(txt, commandMeta):
+		message = getMeta(chatID, language)
+	// summary of heroes
+	case strings.HasPrefix(txt, commandHeroSummary):
+		message = getHeroSummary(chatID, language, collectionModeFrom(argumentOf(txt, commandHeroSummary)))
+	// details of a card
+	case strings.HasPrefix(txt, commandCard):
+		var item *a.MarketItem
+		if message, item = getCard(chatID, argumentOf(txt, commandCard), language); item != nil {
+			message = sendCardImage(b, chatID, *item, message)
+		}
+	// owned cards
+	case strings.HasPrefix(txt, commandOwn):
+		message = manageOwnedCards(chatID, argumentOf(txt, commandOwn), language)
+	// price watches (`commandWatchlist` first, as it is prefixed with `commandWatch`)
+	case strings.HasPrefix(txt, commandWatchlist):
+		message, markup = listWatches(chatID, language)
+	case strings.HasPrefix(txt, commandWatch):
+		message, markup = manageWatches(chatID, argumentOf(txt, commandWatch), language)
+	case strings.HasPrefix(txt, commandUnwatch):
+		message = unwatch(chatID, argumentOf(txt, commandUnwatch), language)
+	// cost to complete the collection, excluding owned cards
+	case strings.HasPrefix(txt, commandRemaining):
+		message = getRemaining(chatID, language, collectionModeFrom(argumentOf(txt, commandRemaining)))
+	// cost of a deck
+	case strings.HasPrefix(txt, commandDeckCost):
+		message = getDeckCost(chatID, argumentOf(txt, commandDeckCost), language)
+	// cards priced close to a price
+	case strings.HasPrefix(txt, commandNearby):
+		message, markup = getNearby(argumentOf(txt, commandNearby), language)
+	// affordable cards
+	case strings.HasPrefix(txt, commandAffordable):
+		message, markup = getAffordable(argumentOf(txt, commandAffordable), language)
+	// set currencies
+	case strings.HasPrefix(txt, commandSetCurrency):
+		message = setCurrenciesWith(chatID, argumentOf(txt, commandSetCurrency), language)
+	// help
+	case strings.HasPrefix(txt, commandHelp):
+		if strings.ToLower(argumentOf(txt, commandHelp)) == "image" {
+			message = sendHelpImage(b, chatID, language)
+		} else {
+			message = getHelp(language)
+		}
+	// notifications of new item types
+	case strings.HasPrefix(txt, commandNotifyNew):
+		if strings.ToLower(argumentOf(txt, commandNotifyNew)) == "off" {
+			unsubscribeNewTypes(chatID)
+			message = localized(language, messageNotifyNewOffEng, messageNotifyNewOffKor)
+		} else {
+			subscribeNewTypes(chatID, language)
+			message = fmt.Sprintf(localized(language, messageNotifyNewOnEng, messageNotifyNewOnKor), commandNotifyNew)
+		}
+	// daily summary
+	case strings.HasPrefix(txt, commandSubscribe):
+		subscribeDailySummary(chatID, language)
+		message = fmt.Sprintf(localized(language, messageSubscribedEng, messageSubscribedKor), conf().DailySummaryHour, time.Now().Format("MST"), commandUnsubscribe)
+	case strings.HasPrefix(txt, commandUnsubscribe):
+		if unsubscribeDailySummary(chatID) {
+			message = localized(language, messageUnsubscribedEng, messageUnsubscribedKor)
+		} else {
+			message = localized(language, messageNotSubscribedEng, messageNotSubscribedKor)
+		}
+	// tax/fee
+	case strings.HasPrefix(txt, commandTax):
+		message = getTax(argumentOf(txt, commandTax), chatID, language)
+	// currency conversion
+	case strings.HasPrefix(txt, commandConvert):
+		message = getConversion(argumentOf(txt, commandConvert), language)
+	// settings
+	case strings.HasPrefix(txt, commandMySettings):
+		message = getMySettings(chatID, language)
+	// list of commands
+	case strings.HasPrefix(txt, commandCommands):
+		message = getCommands(language)
+	// assumptions
+	case strings.HasPrefix(txt, commandAssumptions):
+		message = getAssumptions(language)
+	case strings.HasPrefix(txt, commandResetSettings):
+		resetChatSettings(chatID)
+		message = localized(language, messageResetSettingsEng, messageResetSettingsKor)
+	// report (admin only)
+	case strings.HasPrefix(txt, commandReport) && isAdmin(chatID):
+		message = getReport()
+	// uptime and cache status (admin only)
+	case strings.HasPrefix(txt, commandStats) && isAdmin(chatID):
+		message = getStats()
+	// reload config (admin only)
+	case strings.HasPrefix(txt, commandReloadConfig) && isAdmin(chatID):
+		message = getReloadConfigResult()
+	// reload items (admin only)
+	case strings.HasPrefix(txt, commandReload) && isAdmin(chatID):
+		message = getReloadItemsResult(argumentOf(txt, commandReload))
+	// raw json of a card (admin only)
+	case strings.HasPrefix(txt, commandRaw) && isAdmin(chatID):
+		message = getRaw(argumentOf(txt, commandRaw), language)
+	// cards which look like heroes (admin only)
+	case strings.HasPrefix(txt, commandSuspectHero) && isAdmin(chatID):
+		message = getSuspectHeroes(language)
+	// reset statistics (admin only)
+	case strings.HasPrefix(txt, commandResetStats) && isAdmin(chatID):
+		stats := resetStats()
+		message = fmt.Sprintf(messageResetStats,
+			stats.since.UTC().Format(timestampFormat),
+			stats.numMessages,
+			stats.numInlineQueries,
+			stats.numCacheHits,
+			stats.numCacheMisses,
+		)
+	// export
+	case strings.HasPrefix(txt, commandExport):
+		message = sendExport(b, chatID, argumentOf(txt, commandExport), language)
+	// default action
+	case strings.HasPrefix(txt, commandDefault):
+		message = setDefaultActionWith(chatID, argumentOf(txt, commandDefault), language)
+	// set language
+	case strings.HasPrefix(txt, commandLang):
+		message = setChatLanguageWith(chatID, argumentOf(txt, commandLang), language, langFromUser(update.Message.From))
+	// fallback
+	default:
+		isPlainText := len(txt) > 0 && !strings.HasPrefix(txt, "/")
+
+		switch action := defaultActionOf(chatID); {
+		case isPlainText && action == defaultActionSearch:
+			message, markup = getSearchResults(txt, language)
+		case isPlainText && action == defaultActionSummarize:
+			message = getSummary(chatID, language, collectionModePlayset, a.RarityAll)
+		default:
+			message = getFallbackMessage(txt, update.Message.Chat.Type, language)
+		}
+	}
+
+	stopChatAction()
+
+	// send long message as an image (paginated ones are short enough)
+	if threshold := conf().ImageThresholdChars; threshold > 0 && markup == nil && utf8.RuneCountInString(message) > threshold {
+		err := sendTextImage(b, chatID, message)
+		if err == nil {
+			return true
+		}
+
+		logErrorf("Failed to send message as an image: %s", err)
+	}
+
+	if len(message) > 0 {
+		// send message (split when too long)
+		options := getMessageOptions()
+		if _commandsWithWebPagePreviews[command] {
+			options.SetDisableWebPagePreview(false)
+		}
+		if markup != nil {
+			options.SetReplyMarkup(*markup)
+		}
+
+		for _, splitted := range splitMessage(message) {
+			if err := sendMessage(b, chatID, splitted, options); err == nil {
+				result = true
+			} else {
+				logErrorf("Failed to send message: %s", err)
+			}
+		}
+	}
+
+	return result
+}
+
+// get ttl of cached market items in minutes
+func cacheMinutes() int {
+	if minutes := conf().CacheMinutes; minutes > 0 {
+		return minutes
+	}
+
+	return defaultCacheMinutes
+}
+
+// get ttl of cached market items
+func cacheTTL() time.Duration {
+	return time.Duration(cacheMinutes()) * time.Minute
+}
+
+// get cache time (in seconds) of inline query results in given language
+func inlineCacheSeconds(language a.Lang) int {
+	if seconds, exists := conf().InlineCacheSeconds[_languageCodes[language]]; exists {
+		return seconds
+	}
+	if seconds, exists := conf().InlineCacheSeconds["*"]; exists {
+		return seconds
+	}
+
+	return cacheMinutes() * 60 // default = cache ttl
+}
+
+// process inline query
+func processInlineQuery(b messageSender, update t.Update) bool {
+	// (overrides are looked up with user id, which is the same as the id of the private chat with the user)
+	language := langForChat(int64(update.InlineQuery.From.ID), &update.InlineQuery.From)
+
+	// query length limit differs between languages
+	queryLengthLimit := 3
+	if language == a.LangKorean {
+		queryLengthLimit = 1
+	}
+	// TODO - add more length limits for different languages
+
+	query := sanitizeQuery(update.InlineQuery.Query)
+
+	// strip price filter (eg. "axe <5") from the query,
+	query, filter := parsePriceFilter(query)
+
+	// when query is too short,
+	if len(query) < queryLengthLimit {
+		return false
 	}
 
 	// search with given query,
 	searchedItems := searchItemsByName(query, language)
+	if filter != nil {
+		filtered := []a.MarketItem{}
+		for _, item := range searchedItems {
+			if filter(item.SellPrice) {
+				filtered = append(filtered, item)
+			}
+		}
+		searchedItems = filtered
+	}
 
 	if len(searchedItems) > 0 {
 		itemResults := []interface{}{}
 
+		// (sorted by name, so that pages stay the same while scrolling)
+		searchedItems = append([]a.MarketItem{}, searchedItems...)
+		sort.SliceStable(searchedItems, func(i, j int) bool {
+			return searchedItems[i].Name < searchedItems[j].Name
+		})
+
+		// take the page of given offset,
+		offset, err := strconv.Atoi(update.InlineQuery.Offset)
+		if err != nil || offset < 0 || offset > len(searchedItems) {
+			offset = 0
+		}
+		nextOffset := ""
+		if offset+maxNumInlineResults < len(searchedItems) {
+			searchedItems = searchedItems[offset : offset+maxNumInlineResults]
+			nextOffset = strconv.Itoa(offset + maxNumInlineResults)
+		} else {
+			searchedItems = searchedItems[offset:]
+		}
+
+		// check thumbnails,
+		invalidThumbURLs := map[string]bool{}
+		if conf().ValidateThumbnails {
+			thumbURLs := []string{}
+			for _, item := range searchedItems {
+				thumbURLs = append(thumbURLs, item.AssetDescription.IconURL())
+			}
+			invalidThumbURLs = invalidThumbnails(thumbURLs)
+		}
+
 		// build up inline query results,
 		for _, item := range searchedItems {
 			url := item.StoreURL()
 			thumbURL := item.AssetDescription.IconURL()
 
-			message := fmt.Sprintf("%s (%s)\n%s\n%s", item.Name, item.AssetDescription.Type, item.SellPriceText, url)
+			message := inlineMessageOf(item)
 			description := fmt.Sprintf("%s, %s, %s", item.Name, item.AssetDescription.Type, item.SellPriceText)
 
 			if article, id := t.NewInlineQueryResultArticle(item.Name, message, description); id != nil {
 				article.URL = &url
-				article.ThumbURL = &thumbURL
+				if !invalidThumbURLs[thumbURL] {
+					article.ThumbURL = &thumbURL
+				}
+				if conf().InlineResultButtons {
+					article.ReplyMarkup = cardButtonsOf(item, language)
+				}
 
 				itemResults = append(itemResults, article)
 			}
@@ -539,46 +3615,203 @@ func processInlineQuery(b *t.Bot, update t.Update) bool {
 		sent := b.AnswerInlineQuery(
 			update.InlineQuery.ID,
 			itemResults,
-			nil,
+			t.OptionsAnswerInlineQuery{}.
+				SetCacheTime(inlineCacheSeconds(language)).
+				SetNextOffset(nextOffset),
 		)
 
 		if sent.Ok {
 			return true
 		}
 
-		log.Printf("Failed to answer inline query: %s", *sent.Description)
+		logErrorf("Failed to answer inline query: %s", descriptionOf(sent.APIResponseBase))
 	} else {
-		log.Printf("No matching item with name: %s", query)
+		logDebugf("No matching item with name: %s", query)
+	}
+
+	return false
+}
+
+// process callback query (from inline keyboard buttons)
+func processCallbackQuery(b messageSender, update t.Update) bool {
+	query := update.CallbackQuery
+	chatID := int64(query.From.ID)
+	if query.Message != nil {
+		chatID = query.Message.Chat.ID
 	}
+	language := langForChat(chatID, &query.From)
+
+	var data string
+	if query.Data != nil {
+		data = *query.Data
+	}
+
+	switch {
+	case strings.HasPrefix(data, callbackDataPagePrefix):
+		return processPageCallbackQuery(b, query, data, language)
+	case strings.HasPrefix(data, callbackDataCardPrefix):
+		return processCardCallbackQuery(b, query, data, chatID, language)
+	}
+
+	logWarnf("Unhandled callback query: %s", data)
+
+	b.AnswerCallbackQuery(query.ID, nil)
 
 	return false
 }
 
+// dispatch given update to its handler, recovering from any panic in it
+func handleUpdate(b messageSender, update t.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			logErrorf("Recovered from panic while processing update #%d: %v\n%s", update.UpdateID, r, debug.Stack())
+		}
+	}()
+
+	if update.HasMessage() {
+		atomic.AddInt64(&_numMessages, 1)
+
+		processUpdate(b, update)
+	} else if update.HasInlineQuery() {
+		atomic.AddInt64(&_numInlineQueries, 1)
+
+		processInlineQuery(b, update)
+	} else if update.HasCallbackQuery() {
+		processCallbackQuery(b, update)
+	}
+}
+
+// mark that updates were polled successfully just now
+func markPolled() {
+	atomic.StoreInt64(&_lastPolled, time.Now().UnixNano())
+}
+
+// get the time when updates were polled successfully for the last time
+func lastPolled() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&_lastPolled))
+}
+
+// http transport which marks successful polls of updates (`getUpdates`), even when no update is received
+type pollingTransport struct {
+	http.RoundTripper
+}
+
+func (p pollingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := p.RoundTripper.RoundTrip(req)
+	if err == nil && res.StatusCode == http.StatusOK && strings.HasSuffix(req.URL.Path, "/getUpdates") {
+		markPolled()
+	}
+
+	return res, err
+}
+
+// stop monitoring updates when updates are not polled successfully for given duration,
+// so that it can be restarted
+func watchUpdates(b *t.Bot, timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if elapsed := time.Since(lastPolled()); elapsed > timeout {
+			logWarnf("No successful poll of updates for %s, restarting monitoring...", elapsed.Truncate(time.Second))
+
+			markPolled()
+			b.StopMonitoringUpdates()
+		}
+	}
+}
+
 func main() {
-	bot := t.NewClient(_conf.Token)
-	bot.Verbose = _conf.Verbose
+	_startTime = time.Now()
+
+	// load config and persisted data
+	setup()
+
+	// route outbound requests through proxy
+	applyProxy()
+
+	// mark polls of updates for the watchdog
+	// (before any request is made, as the default transport is replaced)
+	if conf().WatchdogTimeoutSeconds > 0 && conf().WebhookURL == "" {
+		http.DefaultTransport = pollingTransport{http.DefaultTransport}
+	}
+
+	bot := t.NewClient(conf().Token)
+	bot.Verbose = conf().Verbose
 
 	if me := bot.GetMe(); me.Ok {
-		log.Printf("Starting bot: @%s (%s)\n", *me.Result.Username, me.Result.FirstName)
+		logInfof("Starting bot: @%s (%s)", *me.Result.Username, me.Result.FirstName)
 
-		// save bot name
+		// save bot name and client
 		_botName = *me.Result.Username
+		_client = bot
+
+		// reload config on SIGHUP
+		go reloadConfigOnSignal()
+
+		// warm up caches of market items
+		go warmUpCaches()
+
+		// shut down gracefully on SIGINT/SIGTERM
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancelOnSignals(bot, cancel)
+		defer flushPersistedData()
+
+		// monitor new item types for subscribers
+		go monitorNewTypes()
+
+		// record price history periodically
+		go runHistorySnapshots()
+
+		// check watched prices periodically
+		go runPriceWatches()
+
+		// send daily summaries to subscribers
+		go runDailySummaries()
+
+		// send scheduled summaries
+		if len(conf().ScheduledRaritySummaries) > 0 {
+			go runSchedules(conf().ScheduledRaritySummaries)
+		}
+
+		// receive updates through webhook
+		if conf().WebhookURL != "" {
+			if err := startWebhook(ctx, bot); err != nil {
+				panic(fmt.Sprintf("Failed to serve webhook: %s", err))
+			}
+			return
+		}
 
 		// delete webhook first
 		unhooked := bot.DeleteWebhook()
 		if unhooked.Ok {
-			// wait for new updates
-			bot.StartMonitoringUpdates(0, _conf.MonitorIntervalSeconds, func(b *t.Bot, update t.Update, err error) {
-				if err == nil {
-					if update.HasMessage() {
-						processUpdate(b, update)
-					} else if update.HasInlineQuery() {
-						processInlineQuery(b, update)
+			// persist offset of updates periodically
+			go runUpdateOffsetFlushes()
+
+			// watch for stalled updates
+			if conf().WatchdogTimeoutSeconds > 0 {
+				go watchUpdates(bot, time.Duration(conf().WatchdogTimeoutSeconds)*time.Second)
+			}
+
+			// wait for new updates (monitoring is restarted when stopped by the watchdog, until shutdown)
+			for ctx.Err() == nil {
+				markPolled()
+
+				bot.StartMonitoringUpdates(updateOffset(), conf().MonitorIntervalSeconds, func(b *t.Bot, update t.Update, err error) {
+					if err == nil {
+						markPolled()
+
+						handleUpdate(b, update)
+
+						// keep offset for resuming after restart
+						setUpdateOffset(update.UpdateID + 1)
+					} else {
+						logWarnf("Error while receiving update (%s)", err.Error())
 					}
-				} else {
-					log.Printf("Error while receiving update (%s)", err.Error())
-				}
-			})
+				})
+
+				logInfof("Monitoring updates stopped")
+			}
 		} else {
 			panic("Failed to delete webhook")
 		}
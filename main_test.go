@@ -0,0 +1,453 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+	t "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	testChatID  = 1001
+	testAdminID = 2002
+)
+
+// test dispatching commands in messages to their handlers
+func TestProcessUpdate(test *testing.T) {
+	sender := &t.User{ID: testChatID, FirstName: "tester"}
+
+	for _, c := range []struct {
+		name     string
+		chatID   int64
+		txt      string
+		expected string // substring of the reply ("" for no reply)
+	}{
+		{"start", testChatID, "/start", "*Help:*"},
+		{"help", testChatID, "/help", "*Help:*"},
+		{"summarize", testChatID, "/summarize", "*Summary ("},
+		{"summarize with rarity", testChatID, "/summarize rare", "Rare"},
+		{"summarize with unknown argument", testChatID, "/summarize ko", "*Summary ("},
+		{"summarize with bot name", testChatID, "/summarize@testbot", "*Summary ("},
+		{"card", testChatID, "/card axe", "Axe"},
+		{"card without name", testChatID, "/card", "Usage:"},
+		{"card not found", testChatID, "/card nothing like this", "No card matching"},
+		{"tax", testChatID, "/tax 10", "$"},
+		{"settings", testChatID, "/mysettings", "*Your settings:*"},
+		{"unknown command", testChatID, "/unknown", "/unknown: Unknown command"},
+		{"plain text", testChatID, "axe", "axe: Unknown command"},
+		{"empty text", testChatID, "", "Unknown command"},
+		{"escaped fallback", testChatID, "/some_command", `/some\_command: Unknown command`},
+		{"admin command from non-admin", testChatID, "/report", "/report: Unknown command"},
+		{"admin command from admin", testAdminID, "/report", "*Report:*"},
+		{"admin stats from admin", testAdminID, "/stats", "*Stats:*"},
+	} {
+		s := setUpTest(test, config{AdminChatIDs: []int64{testAdminID}}, _testItems)
+
+		processUpdate(s, messageUpdate(c.chatID, sender, c.txt))
+
+		texts := s.texts()
+		if c.expected == "" {
+			if len(texts) > 0 {
+				test.Errorf("%s: expected no reply, got: %v", c.name, texts)
+			}
+			continue
+		}
+		if len(texts) != 1 {
+			test.Errorf("%s: expected 1 reply, got %d: %v", c.name, len(texts), texts)
+			continue
+		}
+		if !strings.Contains(texts[0], c.expected) {
+			test.Errorf("%s: expected reply containing '%s', got: %s", c.name, c.expected, texts[0])
+		}
+	}
+}
+
+// test configured fallback messages, including an empty one for staying silent
+func TestProcessUpdateFallbackMessages(test *testing.T) {
+	s := setUpTest(test, config{
+		FallbackMessages: map[string]map[string]string{
+			"private": map[string]string{"en": "What?"},
+			"group":   map[string]string{"en": ""},
+		},
+	}, _testItems)
+
+	txt := "hello"
+	private := messageUpdate(testChatID, nil, txt)
+	group := t.Update{Message: &t.Message{Chat: &t.Chat{ID: -testChatID, Type: "group"}, Text: &txt}}
+
+	if !processUpdate(s, private) {
+		test.Errorf("expected a reply in private chat")
+	}
+	if processUpdate(s, group) {
+		test.Errorf("expected no reply in group chat")
+	}
+
+	if texts := s.texts(); len(texts) != 1 || texts[0] != "What?" {
+		test.Errorf("unexpected replies: %v", texts)
+	}
+}
+
+// test the default action for plain texts
+func TestProcessUpdateDefaultAction(test *testing.T) {
+	s := setUpTest(test, config{}, _testItems)
+
+	setDefaultAction(testChatID, defaultActionSearch)
+	processUpdate(s, messageUpdate(testChatID, nil, "thunder"))
+
+	if texts := s.texts(); len(texts) != 1 || !strings.Contains(texts[0], "Thunderhide Pack") {
+		test.Errorf("expected search results, got: %v", texts)
+	}
+}
+
+// test that only explicit /summarize records the last check of a chat
+func TestSummaryRecordsOnlyExplicitChecks(test *testing.T) {
+	s := setUpTest(test, config{}, _testItems)
+
+	// (automated or implicit summaries do not record checks)
+	setDefaultAction(testChatID, defaultActionSummarize)
+	processUpdate(s, messageUpdate(testChatID, nil, "hello"))
+	getSummary(testChatID, a.LangEnglish, collectionModePlayset, a.RarityAll)
+
+	processUpdate(s, messageUpdate(testChatID, nil, "/summarize"))
+	processUpdate(s, messageUpdate(testChatID, nil, "/summarize"))
+
+	texts := s.texts()
+	if len(texts) != 3 {
+		test.Fatalf("expected 3 replies, got %d: %v", len(texts), texts)
+	}
+	for i, text := range texts {
+		recorded := strings.Contains(text, "since your last check")
+		if expected := i == 2; recorded != expected {
+			test.Errorf("reply #%d: expected change since the last check to be shown (%t), got: %s", i, expected, text)
+		}
+	}
+}
+
+// source of market items which panics
+type panickingSource struct{}
+
+func (panickingSource) FetchAll(rarity a.Rarity, language a.Lang, column a.SortColumn, direction a.SortDirection) ([]a.MarketItem, error) {
+	panic("panic in source")
+}
+
+// test that a panic in a command handler is recovered, and following updates are still processed
+func TestHandleUpdateRecoversFromPanic(test *testing.T) {
+	s := setUpTest(test, config{}, nil)
+
+	// (handler of /summarize panics while fetching items)
+	clearItemsCache()
+	_itemsSource = panickingSource{}
+
+	handleUpdate(s, messageUpdate(testChatID, nil, "/summarize"))
+	if texts := s.texts(); len(texts) > 0 {
+		test.Errorf("expected no reply from the panicked handler, got: %v", texts)
+	}
+
+	handleUpdate(s, messageUpdate(testChatID, nil, "/help"))
+	if texts := s.texts(); len(texts) != 1 || !strings.Contains(texts[0], "*Help:*") {
+		test.Errorf("expected a reply to the next update, got: %v", texts)
+	}
+}
+
+// test messages without a sender (eg. from anonymous group admins)
+func TestProcessUpdateWithoutSender(test *testing.T) {
+	s := setUpTest(test, config{
+		CommandCooldownSeconds: map[string]int{commandSummarize: 60},
+	}, _testItems)
+
+	for _, txt := range []string{
+		"/summarize", // (cooldown is keyed by chat id)
+		"/summarize",
+		"/lang auto",
+		"/own add axe",
+		"/mysettings",
+	} {
+		txt := txt
+		update := t.Update{Message: &t.Message{Chat: &t.Chat{ID: -testChatID, Type: "supergroup"}, Text: &txt}}
+
+		if !processUpdate(s, update) {
+			test.Errorf("%s: expected a reply", txt)
+		}
+	}
+
+	texts := s.texts()
+	if len(texts) != 5 {
+		test.Fatalf("expected 5 replies, got %d: %v", len(texts), texts)
+	}
+	for i, expected := range []string{
+		"*Summary (",
+		"Please wait", // cooling down
+		"language of your Telegram app",
+		"You own 1 of Axe now.",
+		"*Your settings:*", // in English (default)
+	} {
+		if !strings.Contains(texts[i], expected) {
+			test.Errorf("reply #%d: expected '%s', got: %s", i, expected, texts[i])
+		}
+	}
+}
+
+// test that ranked items are paginated with `max_listed_items` in config
+func TestRankedItemsPaginatedWithConfig(test *testing.T) {
+	setUpTest(test, config{MaxListedItems: 2}, _testItems)
+
+	message, markup, items := getTop("10", a.LangEnglish)
+	if len(items) != 3 || markup == nil {
+		test.Errorf("expected 3 items in pages, got %d (paginated: %t)", len(items), markup != nil)
+	}
+	if !strings.Contains(message, "1. Axe") || strings.Contains(message, "3. Keefe the Bold") || !strings.Contains(message, "page 1 of 2") {
+		test.Errorf("expected the first page only, got: %s", message)
+	}
+
+	if _, markup, items := getCheapest("1", a.LangEnglish); len(items) != 1 || markup != nil {
+		test.Errorf("expected 1 item in a single page, got %d (paginated: %t)", len(items), markup != nil)
+	}
+}
+
+// test that list commands are paginated with `max_listed_items` in config
+func TestListCommandsPaginated(test *testing.T) {
+	setUpTest(test, config{MaxListedItems: 1}, _testItems)
+
+	if message, markup := getAffordable("rare 100", a.LangEnglish); markup != nil || !strings.Contains(message, "Axe") {
+		test.Errorf("expected a single page of affordable cards, got: %s", message)
+	}
+	if message, markup := getNearby("0.10 0.10", a.LangEnglish); markup == nil || !strings.Contains(message, "page 1 of 2") {
+		test.Errorf("expected pages of nearby cards, got: %s", message)
+	}
+
+	s := setUpTest(test, config{MaxListedItems: 1}, _testItems)
+	processUpdate(s, messageUpdate(testChatID, nil, "/top 3"))
+	if texts := s.texts(); len(texts) != 1 || !strings.Contains(texts[0], "page 1 of 3") {
+		test.Errorf("expected the first page of /top, got: %v", texts)
+	}
+}
+
+// test that /watchlist lists watched prices, flagging the ones already reached
+func TestWatchlist(test *testing.T) {
+	s := setUpTest(test, config{}, _testItems)
+
+	reached, notReached := _testItems[0], _testItems[1]
+	addPriceWatch(testChatID, priceWatch{HashName: reached.HashName, Name: reached.Name, Language: a.LangEnglish, Threshold: reached.SellPrice})
+	addPriceWatch(testChatID, priceWatch{HashName: notReached.HashName, Name: notReached.Name, Language: a.LangEnglish, Threshold: notReached.SellPrice + 100, Rising: true})
+
+	processUpdate(s, messageUpdate(testChatID, nil, "/watchlist"))
+
+	texts := s.texts()
+	if len(texts) != 1 || strings.Count(texts[0], "(reached)") != 1 {
+		test.Fatalf("expected a watchlist with one reached watch, got: %v", texts)
+	}
+	for _, line := range strings.Split(texts[0], "\n") {
+		if strings.Contains(line, "(reached)") && !strings.Contains(line, reached.Name) {
+			test.Errorf("expected '%s' to be flagged as reached, got: %s", reached.Name, line)
+		}
+	}
+}
+
+// test that only the exact price watch which fired is removed
+func TestRemoveFiredPriceWatch(test *testing.T) {
+	s := setUpTest(test, config{}, _testItems)
+
+	item := _testItems[0]
+	fired := priceWatch{HashName: item.HashName, Name: item.Name, Language: a.LangEnglish, Threshold: item.SellPrice, Created: time.Now()}
+	addPriceWatch(testChatID, fired)
+
+	// replaced with a new watch of the same card before the fired one is removed
+	replaced := fired
+	replaced.Threshold, replaced.Created = item.SellPrice/2, fired.Created.Add(time.Second)
+	addPriceWatch(testChatID, replaced)
+
+	if removeExactPriceWatch(testChatID, fired) {
+		test.Errorf("expected the replaced watch not to be removed")
+	}
+	if watches := priceWatchesOf(testChatID); len(watches) != 1 || watches[0].Threshold != replaced.Threshold {
+		test.Errorf("expected the new watch to be kept, got: %+v", watches)
+	}
+
+	addPriceWatch(testChatID, fired)
+	checkPriceWatches(s)
+
+	if n := len(s.texts()); n != 1 {
+		test.Errorf("expected 1 price alert, got %d", n)
+	}
+	if watches := priceWatchesOf(testChatID); len(watches) != 0 {
+		test.Errorf("expected the fired watch to be removed, got: %+v", watches)
+	}
+}
+
+// test that chat actions are not sent for messages which are cooling down or falling back
+func TestNoChatActionsWithoutHandling(test *testing.T) {
+	s := setUpTest(test, config{
+		CommandCooldownSeconds: map[string]int{commandTax: 60},
+		FallbackMessages: map[string]map[string]string{
+			"group": map[string]string{"en": ""},
+		},
+	}, _testItems)
+
+	// (give chat actions, if started, time to be sent)
+	wait := func() { time.Sleep(10 * time.Millisecond) }
+
+	processUpdate(s, messageUpdate(testChatID, nil, "/tax 10"))
+	wait()
+	numChatActions := s.numChatActions()
+
+	txt := "hello"
+	processUpdate(s, t.Update{Message: &t.Message{Chat: &t.Chat{ID: -testChatID, Type: "group"}, Text: &txt}})
+	processUpdate(s, messageUpdate(testChatID, nil, "/unknown"))
+	processUpdate(s, messageUpdate(testChatID, nil, "/report")) // (admin command from non-admin)
+	processUpdate(s, messageUpdate(testChatID, nil, "/tax 10")) // cooling down
+	wait()
+
+	if n := s.numChatActions(); n != numChatActions {
+		test.Errorf("expected no chat action without handling, got %d more", n-numChatActions)
+	}
+}
+
+// test that handled texts are recognized, including admin commands and default actions
+func TestIsHandledText(test *testing.T) {
+	setUpTest(test, config{AdminChatIDs: []int64{testAdminID}}, _testItems)
+
+	for _, c := range []struct {
+		txt      string
+		chatID   int64
+		expected bool
+	}{
+		{"/summarize", testChatID, true},
+		{"/summarize@testbot rare", testChatID, true},
+		{"/unknown", testChatID, false},
+		{"/report", testChatID, false},
+		{"/report", testAdminID, true},
+		{"axe", testChatID, false},
+		{"", testChatID, false},
+	} {
+		if handled := isHandledText(c.txt, c.chatID); handled != c.expected {
+			test.Errorf("'%s' in chat #%d: expected %t, got %t", c.txt, c.chatID, c.expected, handled)
+		}
+	}
+
+	setDefaultAction(testChatID, defaultActionSearch)
+	if !isHandledText("axe", testChatID) {
+		test.Errorf("expected plain text to be handled with the default action")
+	}
+}
+
+// test chat actions of commands
+func TestChatActionFor(test *testing.T) {
+	for txt, expected := range map[string]t.ChatAction{
+		"/summarize":    t.ChatActionTyping,
+		"/card axe":     t.ChatActionUploadPhoto,
+		"/top 3":        t.ChatActionUploadPhoto,
+		"/cheapest":     t.ChatActionUploadPhoto,
+		"/export csv":   t.ChatActionUploadDocument,
+		"/help":         t.ChatActionTyping,
+		"/help  Image ": t.ChatActionUploadPhoto,
+	} {
+		if action := chatActionFor(txt); action != expected {
+			test.Errorf("'%s': expected %s, got %s", txt, expected, action)
+		}
+	}
+}
+
+// test that basic heroes are derived from (built-in or configured) lists of heroes
+func TestSetHeroes(test *testing.T) {
+	defer setHeroes(_builtInHeroes)
+
+	setHeroes(heroesWithConfigured(_builtInHeroes, map[string][]string{
+		"ko": []string{"도끼전사", "새 영웅" + basicHeroSuffix},
+	}))
+
+	for _, c := range []struct {
+		name     string
+		language a.Lang
+		hero     bool
+		basic    bool
+	}{
+		{"Axe", a.LangEnglish, true, false},
+		{"Keefe the Bold", a.LangEnglish, true, true},
+		{"Keefe the Bold" + basicHeroSuffix, a.LangEnglish, false, false},
+		{"도끼전사", a.LangKorean, true, false},
+		{"새 영웅", a.LangKorean, true, true},
+		{"교활한 데비", a.LangKorean, false, false}, // (replaced with configured ones)
+	} {
+		if hero := isHero(c.name, c.language); hero != c.hero {
+			test.Errorf("%s: expected hero %t, got %t", c.name, c.hero, hero)
+		}
+		if basic := isBasicHero(c.name, c.language); basic != c.basic {
+			test.Errorf("%s: expected basic hero %t, got %t", c.name, c.basic, basic)
+		}
+	}
+}
+
+// test that emojis of rarities are chosen deterministically with overlapping keywords
+func TestRarityEmoji(test *testing.T) {
+	setUpTest(test, config{
+		RarityEmojis: map[string]string{
+			"rare":   "🟣",
+			"희귀":     "💜",
+			"common": "⚪",
+		},
+	}, _testItems)
+
+	for i := 0; i < 20; i++ {
+		if emoji := rarityEmoji(a.RarityRare); emoji != "🟣 " {
+			test.Fatalf("expected emoji of the first keyword in sorted order, got: '%s'", emoji)
+		}
+	}
+	if emoji := rarityEmoji(a.RarityCommon); emoji != "⚪ " {
+		test.Errorf("expected emoji of common, got: '%s'", emoji)
+	}
+	if emoji := rarityEmoji(a.RarityUncommon); emoji != "" {
+		test.Errorf("expected no emoji of uncommon, got: '%s'", emoji)
+	}
+}
+
+// test that offsets of updates are persisted only when flushed
+func TestFlushUpdateOffset(test *testing.T) {
+	setUpTest(test, config{}, _testItems)
+
+	setUpdateOffset(10)
+	setUpdateOffset(11)
+	setUpdateOffset(5) // (older ones are ignored)
+
+	if _savedUpdateOffset != 0 {
+		test.Errorf("expected offset not to be saved on each update, got %d", _savedUpdateOffset)
+	}
+	if !flushUpdateOffset() || _savedUpdateOffset != 11 {
+		test.Errorf("expected offset 11 to be saved, got %d", _savedUpdateOffset)
+	}
+	if flushUpdateOffset() {
+		test.Errorf("expected no save without changes")
+	}
+}
+
+// test that cooldowns apply to commands with bot names too
+func TestCooldownWithBotName(test *testing.T) {
+	s := setUpTest(test, config{
+		CommandCooldownSeconds: map[string]int{commandTax: 60},
+	}, _testItems)
+
+	processUpdate(s, messageUpdate(testChatID, nil, "/tax 10"))
+	processUpdate(s, messageUpdate(testChatID, nil, "/tax@testbot 10"))
+
+	if texts := s.texts(); len(texts) != 2 || !strings.Contains(texts[1], "Please wait") {
+		test.Errorf("expected the command with bot name to be cooling down, got: %v", texts)
+	}
+}
+
+// test that unknown commands in cooldowns are rejected
+func TestValidateCooldowns(test *testing.T) {
+	setUpTest(test, config{}, _testItems)
+
+	valid := config{Token: "123:abc", MonitorIntervalSeconds: 1}
+
+	valid.CommandCooldownSeconds = map[string]int{commandSummarize: 10, commandReport: 10}
+	if err := validateConfig(valid); err != nil {
+		test.Errorf("expected valid cooldowns, got: %s", err)
+	}
+
+	valid.CommandCooldownSeconds = map[string]int{"/sumarize": 10}
+	if err := validateConfig(valid); err == nil || !strings.Contains(err.Error(), "/sumarize") {
+		test.Errorf("expected unknown command to be rejected, got: %v", err)
+	}
+}
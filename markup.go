@@ -0,0 +1,142 @@
+package main
+
+// markup.go
+//
+// messages are written in Telegram's (legacy) Markdown,
+// and converted to HTML when `parse_mode` is set to "html" in config
+
+import (
+	"html"
+	"strings"
+
+	t "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	parseModeMarkdown = "markdown"
+	parseModeHTML     = "html"
+)
+
+// characters which should be escaped outside of entities in Markdown
+const markdownSpecialChars = "_*`["
+
+// get parse mode of messages from config (default: Markdown)
+func parseMode() t.ParseMode {
+	if strings.ToLower(conf().ParseMode) == parseModeHTML {
+		return t.ParseModeHTML
+	}
+
+	return t.ParseModeMarkdown
+}
+
+// escape given text for embedding it in Markdown messages (outside of entities)
+func escapeMarkdown(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(markdownSpecialChars, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// format given Markdown message for the parse mode in config
+func formatMessage(message string) string {
+	if parseMode() == t.ParseModeHTML {
+		return markdownToHTML(message)
+	}
+
+	return message
+}
+
+// functions for rendering parts of a (legacy) Markdown message
+type markdownRenderer struct {
+	text   func(text string) string              // plain texts, including escaped characters
+	entity func(marker rune, text string) string // texts in `*`, `_` or `` ` ``
+	link   func(text, url string) string         // [text](url)
+}
+
+// render given (legacy) Markdown message with given renderer
+//
+// (entities are not nested, unclosed markers and backslash-escaped characters are treated as texts)
+func renderMarkdown(message string, renderer markdownRenderer) string {
+	var b strings.Builder
+
+	runes := []rune(message)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		// escaped characters
+		if r == '\\' && i+1 < len(runes) && strings.ContainsRune(markdownSpecialChars, runes[i+1]) {
+			b.WriteString(renderer.text(string(runes[i+1])))
+			i++
+			continue
+		}
+
+		switch r {
+		case '*', '_', '`':
+			if end := indexRune(runes, r, i+1); end > i+1 {
+				b.WriteString(renderer.entity(r, string(runes[i+1:end])))
+				i = end
+				continue
+			}
+		case '[': // [text](url)
+			if closing := indexRune(runes, ']', i+1); closing > i+1 && closing+1 < len(runes) && runes[closing+1] == '(' {
+				if end := indexRune(runes, ')', closing+2); end > closing+2 {
+					b.WriteString(renderer.link(string(runes[i+1:closing]), string(runes[closing+2:end])))
+					i = end
+					continue
+				}
+			}
+		}
+
+		b.WriteString(renderer.text(string(r)))
+	}
+
+	return b.String()
+}
+
+// convert given (legacy) Markdown message to HTML
+func markdownToHTML(message string) string {
+	return renderMarkdown(message, markdownRenderer{
+		text: html.EscapeString,
+		entity: func(marker rune, text string) string {
+			tag := map[rune]string{'*': "b", '_': "i", '`': "code"}[marker]
+
+			return "<" + tag + ">" + html.EscapeString(text) + "</" + tag + ">"
+		},
+		link: func(text, url string) string {
+			return `<a href="` + html.EscapeString(url) + `">` + html.EscapeString(text) + "</a>"
+		},
+	})
+}
+
+// convert given (legacy) Markdown message to plain text (eg. for drawing it in images)
+//
+// (links are converted to "text (url)")
+func markdownToText(message string) string {
+	return renderMarkdown(message, markdownRenderer{
+		text: func(text string) string {
+			return text
+		},
+		entity: func(marker rune, text string) string {
+			return text
+		},
+		link: func(text, url string) string {
+			return text + " (" + url + ")"
+		},
+	})
+}
+
+// get index of given rune in given runes, starting from `from` (-1 if none)
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+// test converting Markdown messages to HTML
+func TestMarkdownToHTML(test *testing.T) {
+	for message, expected := range map[string]string{
+		"*Summary:* <Axe>":            "<b>Summary:</b> &lt;Axe&gt;",
+		"_italic_ and `code`":         "<i>italic</i> and <code>code</code>",
+		`/some\_command: \*`:          "/some_command: *",
+		"[Steam](https://steam/?a&b)": `<a href="https://steam/?a&amp;b">Steam</a>`,
+		"unclosed *marker":            "unclosed *marker",
+	} {
+		if converted := markdownToHTML(message); converted != expected {
+			test.Errorf("'%s': expected '%s', got '%s'", message, expected, converted)
+		}
+	}
+}
+
+// test converting Markdown messages to plain texts
+func TestMarkdownToText(test *testing.T) {
+	for message, expected := range map[string]string{
+		"*Summary:* <Axe>":            "Summary: <Axe>",
+		"_italic_ and `code`":         "italic and code",
+		`/some\_command: \*`:          "/some_command: *",
+		`- Axe\_Bristle \[1]`:         "- Axe_Bristle [1]",
+		`C:\path`:                     `C:\path`,
+		"[Steam](https://steam/?a&b)": "Steam (https://steam/?a&b)",
+		"unclosed *marker":            "unclosed *marker",
+	} {
+		if converted := markdownToText(message); converted != expected {
+			test.Errorf("'%s': expected '%s', got '%s'", message, expected, converted)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+// notifications.go
+//
+// notifications of new card types which appeared in the market, for subscribed chats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	messageNewTypesEng = "*New card types appeared in the market:*\n\n%s"
+	messageNewTypesKor = "*장터에 새로운 카드 종류가 등장했습니다:*\n\n%s"
+)
+
+// check item types of given items, and notify subscribers of newly appeared ones
+func checkNewTypes(b messageSender, language a.Lang, items []a.MarketItem) {
+	types := []string{}
+	for _, item := range items {
+		types = append(types, item.AssetDescription.Type)
+	}
+
+	newTypes := recordItemTypes(language, types)
+	if len(newTypes) <= 0 {
+		return
+	}
+
+	logInfof("New item types appeared (%s): %s", language, strings.Join(newTypes, ", "))
+
+	lines := []string{}
+	for _, itemType := range newTypes {
+		lines = append(lines, "- "+escapeMarkdown(itemType))
+	}
+	message := fmt.Sprintf(localized(language, messageNewTypesEng, messageNewTypesKor), strings.Join(lines, "\n"))
+
+	for _, chatID := range newTypeSubscribers(language) {
+		if err := sendMessage(b, chatID, message, getMessageOptions()); err != nil {
+			logErrorf("Failed to notify new item types: %s", err)
+		}
+	}
+}
+
+// periodically reload items of languages which have subscribers, so that new item types are noticed
+func monitorNewTypes() {
+	for {
+		time.Sleep(cacheTTL())
+
+		for _, language := range _languages {
+			if len(newTypeSubscribers(language)) > 0 {
+				getItems(language)
+			}
+		}
+	}
+}
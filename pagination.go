@@ -0,0 +1,210 @@
+package main
+
+// pagination.go
+//
+// long lists of items are sent as one message with inline 'prev/next' buttons,
+// and the message is edited in place when the buttons are pressed
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+	t "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	maxNumPagedResults           = 100 // max number of paged results kept in memory
+	pagedResultExpirationMinutes = 60  // paged results older than this are dropped
+
+	callbackDataPagePrefix = "page:" // "page:[token]:[page index]"
+
+	messagePagePrevEng    = "◀ Prev"
+	messagePagePrevKor    = "◀ 이전"
+	messagePageNextEng    = "Next ▶"
+	messagePageNextKor    = "다음 ▶"
+	messagePageIndexEng   = "_(page %d of %d)_"
+	messagePageIndexKor   = "_(%d / %d 페이지)_"
+	messagePageExpiredEng = "This list has expired. Please send the command again."
+	messagePageExpiredKor = "만료된 목록입니다. 명령을 다시 보내 주세요."
+)
+
+// paged result set
+type pagedResult struct {
+	header   string // format string with one '%s' for the lines of a page
+	lines    []string
+	language a.Lang
+	created  time.Time
+}
+
+var _pages = map[string]*pagedResult{}
+var _pagesLock sync.Mutex
+var _pageTokenSeq int64
+
+// number of pages of this result
+func (r *pagedResult) numPages() int {
+	perPage := maxNumListedItems()
+
+	return (len(r.lines) + perPage - 1) / perPage
+}
+
+// render given page of this result (page index starts from 0)
+func (r *pagedResult) render(token string, page int) (message string, markup t.InlineKeyboardMarkup) {
+	perPage, numPages := maxNumListedItems(), r.numPages()
+	if page < 0 {
+		page = 0
+	} else if page >= numPages {
+		page = numPages - 1
+	}
+
+	from, to := page*perPage, (page+1)*perPage
+	if to > len(r.lines) {
+		to = len(r.lines)
+	}
+
+	message = fmt.Sprintf(r.header, strings.Join(r.lines[from:to], "\n")) +
+		"\n\n" + fmt.Sprintf(localized(r.language, messagePageIndexEng, messagePageIndexKor), page+1, numPages)
+
+	buttons := []t.InlineKeyboardButton{}
+	if page > 0 {
+		buttons = append(buttons, callbackButton(localized(r.language, messagePagePrevEng, messagePagePrevKor), pageCallbackData(token, page-1)))
+	}
+	if page < numPages-1 {
+		buttons = append(buttons, callbackButton(localized(r.language, messagePageNextEng, messagePageNextKor), pageCallbackData(token, page+1)))
+	}
+	markup = t.InlineKeyboardMarkup{
+		InlineKeyboard: [][]t.InlineKeyboardButton{buttons},
+	}
+
+	return message, markup
+}
+
+// generate an inline keyboard button with callback data
+func callbackButton(text, data string) t.InlineKeyboardButton {
+	return t.InlineKeyboardButton{
+		Text:         text,
+		CallbackData: &data,
+	}
+}
+
+// generate callback data for given page of a paged result
+func pageCallbackData(token string, page int) string {
+	return fmt.Sprintf("%s%s:%d", callbackDataPagePrefix, token, page)
+}
+
+// paginate given lines with header (a format string with one '%s')
+//
+// returns nil markup when all lines fit in a single page
+func paginate(header string, lines []string, language a.Lang) (message string, markup *t.InlineKeyboardMarkup) {
+	if len(lines) <= maxNumListedItems() {
+		return fmt.Sprintf(header, strings.Join(lines, "\n")), nil
+	}
+
+	result := &pagedResult{
+		header:   header,
+		lines:    lines,
+		language: language,
+		created:  time.Now(),
+	}
+
+	_pagesLock.Lock()
+	_pageTokenSeq++
+	token := strconv.FormatInt(_pageTokenSeq, 36)
+	_pages[token] = result
+	prunePagedResults()
+	_pagesLock.Unlock()
+
+	message, rendered := result.render(token, 0)
+
+	return message, &rendered
+}
+
+// remove expired paged results, and the oldest ones over `maxNumPagedResults`
+//
+// (caller should hold `_pagesLock`)
+func prunePagedResults() {
+	expired := time.Now().Add(-pagedResultExpirationMinutes * time.Minute)
+
+	for token, result := range _pages {
+		if result.created.Before(expired) {
+			delete(_pages, token)
+		}
+	}
+
+	for len(_pages) > maxNumPagedResults {
+		var oldest string
+		for token, result := range _pages {
+			if oldest == "" || result.created.Before(_pages[oldest].created) {
+				oldest = token
+			}
+		}
+		delete(_pages, oldest)
+	}
+}
+
+// get paged result with given token
+func pagedResultOf(token string) (*pagedResult, bool) {
+	_pagesLock.Lock()
+	defer _pagesLock.Unlock()
+
+	prunePagedResults()
+
+	result, exists := _pages[token]
+
+	return result, exists
+}
+
+// parse callback data of a page
+func parsePageCallbackData(data string) (token string, page int, err error) {
+	if !strings.HasPrefix(data, callbackDataPagePrefix) {
+		return "", 0, fmt.Errorf("not a page callback: %s", data)
+	}
+
+	splitted := strings.Split(strings.TrimPrefix(data, callbackDataPagePrefix), ":")
+	if len(splitted) != 2 {
+		return "", 0, fmt.Errorf("malformed page callback: %s", data)
+	}
+
+	if page, err = strconv.Atoi(splitted[1]); err != nil {
+		return "", 0, err
+	}
+
+	return splitted[0], page, nil
+}
+
+// process callback query of page navigation
+func processPageCallbackQuery(b messageSender, query *t.CallbackQuery, data string, language a.Lang) bool {
+	token, page, err := parsePageCallbackData(data)
+	if err != nil {
+		logWarnf("Malformed callback query: %s", err)
+
+		b.AnswerCallbackQuery(query.ID, nil)
+		return false
+	}
+
+	result, exists := pagedResultOf(token)
+	if !exists || query.Message == nil {
+		b.AnswerCallbackQuery(query.ID, t.OptionsAnswerCallbackQuery{}.
+			SetText(localized(language, messagePageExpiredEng, messagePageExpiredKor)))
+		return false
+	}
+
+	message, markup := result.render(token, page)
+	edited := b.EditMessageText(formatMessage(message), t.OptionsEditMessageText{}.
+		SetIDs(query.Message.Chat.ID, query.Message.MessageID).
+		SetParseMode(parseMode()).
+		SetDisableWebPagePreview(!conf().ShowWebPagePreviews).
+		SetReplyMarkup(markup))
+
+	b.AnswerCallbackQuery(query.ID, nil)
+
+	if !edited.Ok {
+		logErrorf("Failed to edit message: %s", descriptionOf(edited.APIResponseBase))
+		return false
+	}
+
+	return true
+}
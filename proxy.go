@@ -0,0 +1,59 @@
+package main
+
+// proxy.go
+//
+// outbound requests are routed through an http(s) or socks5 proxy, when `proxy_url` is set in config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// supported schemes of proxy urls
+var _proxySchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"socks5": true,
+}
+
+// parse and validate given proxy url
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if !_proxySchemes[u.Scheme] {
+		return nil, fmt.Errorf("unsupported scheme of proxy: '%s'", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no host in proxy url: '%s'", proxyURL)
+	}
+
+	return u, nil
+}
+
+// route outbound requests through the proxy in config
+//
+// (applied to the default transport, which is shared by the Steam market fetcher,
+// the Telegram client, and requests for exchange rates and thumbnails)
+func applyProxy() {
+	proxyURL := conf().ProxyURL
+	if proxyURL == "" {
+		return
+	}
+
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		logWarnf("Not using proxy: %s", err)
+		return
+	}
+
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.Proxy = http.ProxyURL(u)
+
+		logInfof("Using proxy %s://%s for Steam Community Market, Telegram Bot API, exchange rates, and thumbnails", u.Scheme, u.Host)
+	} else {
+		logWarnf("Not using proxy: default transport is not replaceable")
+	}
+}
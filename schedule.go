@@ -0,0 +1,112 @@
+package main
+
+// schedule.go
+//
+// daily summaries for subscribers, and summaries of rarities at the times of `scheduled_rarity_summaries` in config
+
+import (
+	"fmt"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	// format of scheduled times
+	scheduleTimeFormat = "15:04"
+)
+
+// summary of a rarity which is sent to a chat at a fixed time every day
+type scheduledRaritySummary struct {
+	Rarity   string `json:"rarity"`             // rarity keyword (eg. "rare")
+	ChatID   int64  `json:"chat_id"`            // chat id to send the summary to
+	Time     string `json:"time"`               // local time of day in "HH:MM" format
+	Language string `json:"language,omitempty"` // language code (default: "en")
+}
+
+// validate given scheduled summaries
+func validateSchedules(schedules []scheduledRaritySummary) (problems []string) {
+	for i, schedule := range schedules {
+		if _, exists := _rarityKeywords[schedule.Rarity]; !exists {
+			problems = append(problems, fmt.Sprintf("unknown rarity in scheduled summary #%d: '%s'", i, schedule.Rarity))
+		}
+		if _, err := time.Parse(scheduleTimeFormat, schedule.Time); err != nil {
+			problems = append(problems, fmt.Sprintf("malformed time in scheduled summary #%d: '%s'", i, schedule.Time))
+		}
+		if _, exists := langFromCode(schedule.Language); schedule.Language != "" && !exists {
+			problems = append(problems, fmt.Sprintf("no localization for language in scheduled summary #%d: '%s'", i, schedule.Language))
+		}
+	}
+
+	return problems
+}
+
+// check if given schedule is due at given time
+//
+// (compared with parsed hour and minute, as times like "9:00" are also valid)
+func isScheduledAt(schedule scheduledRaritySummary, now time.Time) bool {
+	parsed, err := time.Parse(scheduleTimeFormat, schedule.Time)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Hour() == now.Hour() && parsed.Minute() == now.Minute()
+}
+
+// send summaries to subscribers of daily summaries at `daily_summary_hour` every day
+//
+// (subscribers are copied before sending, so (un)subscriptions meanwhile take effect from the next day)
+func runDailySummaries() {
+	for now := range time.Tick(time.Minute) {
+		if now.Hour() != conf().DailySummaryHour || now.Minute() != 0 {
+			continue
+		}
+
+		sendDailySummaries(_client)
+	}
+}
+
+// send daily summaries to subscribers, skipping unreachable chats
+func sendDailySummaries(b messageSender) {
+	for chatID, language := range dailySummarySubscribers() {
+		if isChatBlocked(chatID) {
+			logInfof("Skipping daily summary to unreachable chat %d", chatID)
+			continue
+		}
+
+		if chatLanguage, exists := chatLanguageOf(chatID); exists {
+			language = chatLanguage
+		}
+
+		message := getSummary(chatID, language, collectionModePlayset, a.RarityAll)
+		if err := sendMessage(b, chatID, message, getMessageOptions()); err != nil {
+			logErrorf("Failed to send daily summary: %s", err)
+		}
+	}
+}
+
+// send scheduled summaries when their times come
+func runSchedules(schedules []scheduledRaritySummary) {
+	for now := range time.Tick(time.Minute) {
+		for _, schedule := range schedules {
+			if !isScheduledAt(schedule, now) {
+				continue
+			}
+
+			if isChatBlocked(schedule.ChatID) {
+				logInfof("Skipping scheduled summary to unreachable chat %d", schedule.ChatID)
+				continue
+			}
+
+			language, exists := langFromCode(schedule.Language)
+			if !exists {
+				language = a.LangEnglish
+			}
+
+			message := getRaritySummary(schedule.ChatID, language, _rarityKeywords[schedule.Rarity], collectionModePlayset)
+			if err := sendMessage(_client, schedule.ChatID, message, getMessageOptions()); err != nil {
+				logErrorf("Failed to send scheduled summary: %s", err)
+			}
+		}
+	}
+}
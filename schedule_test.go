@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+// test that schedules are due at their times, including ones with single-digit hours
+func TestIsScheduledAt(test *testing.T) {
+	now := time.Date(2019, 3, 1, 9, 5, 30, 0, time.Local)
+
+	for scheduled, expected := range map[string]bool{
+		"09:05": true,
+		"9:05":  true,
+		"9:06":  false,
+		"21:05": false,
+		"9h05":  false, // malformed
+	} {
+		if due := isScheduledAt(scheduledRaritySummary{Time: scheduled}, now); due != expected {
+			test.Errorf("'%s': expected %t, got %t", scheduled, expected, due)
+		}
+	}
+}
+
+// test that daily summaries are not sent to blocked chats
+func TestDailySummariesSkipBlockedChats(test *testing.T) {
+	s := setUpTest(test, config{}, _testItems)
+
+	subscribeDailySummary(testChatID, a.LangEnglish)
+	subscribeDailySummary(testAdminID, a.LangEnglish)
+	markChatBlocked(testAdminID)
+
+	sendDailySummaries(s)
+
+	if n := len(s.texts()); n != 1 {
+		test.Errorf("expected 1 daily summary, got %d", n)
+	}
+}
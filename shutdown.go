@@ -0,0 +1,45 @@
+package main
+
+// shutdown.go
+//
+// graceful shutdown on SIGINT/SIGTERM
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	t "github.com/meinside/telegram-bot-go"
+)
+
+// wait for SIGINT/SIGTERM, then cancel given context and stop receiving updates
+func cancelOnSignals(b *t.Bot, cancel context.CancelFunc) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-ch
+	logInfof("Received %s, shutting down...", sig)
+
+	cancel()
+	b.StopMonitoringUpdates()
+}
+
+// save persisted data to files before exiting
+//
+// (also waits for writes in progress, as they hold the same locks)
+func flushPersistedData() {
+	saveItemsCache()
+
+	_stateLock.Lock()
+	saveState()
+	_stateLock.Unlock()
+
+	_historyLock.Lock()
+	if err := saveJSONFile(historyFilename, _history); err != nil {
+		logErrorf("Failed to save history: %s", err)
+	}
+	_historyLock.Unlock()
+
+	logInfof("Saved persisted data")
+}
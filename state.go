@@ -0,0 +1,732 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+)
+
+const (
+	// state filename
+	stateFilename = "state.json"
+
+	// max number of kept changes of cards' types (per language)
+	maxNumTypeChanges = 50
+
+	// interval of persisting the offset of updates
+	updateOffsetFlushSeconds = 30
+)
+
+// price with the time it was observed
+type priceRecord struct {
+	Price int       `json:"price"` // in cents
+	Time  time.Time `json:"time"`
+}
+
+// change of a card's type
+type typeChange struct {
+	Name string    `json:"name"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// watch of a card's price
+type priceWatch struct {
+	HashName  string    `json:"hash_name"`
+	Name      string    `json:"name"`
+	Language  a.Lang    `json:"language"`
+	Threshold int       `json:"threshold"` // in cents
+	Rising    bool      `json:"rising"`    // alert when the price rises to the threshold (or when it falls to the threshold)
+	Created   time.Time `json:"created"`
+}
+
+// check if given price crossed the threshold of this watch
+func (w priceWatch) crossed(price int) bool {
+	if w.Rising {
+		return price >= w.Threshold
+	}
+
+	return price > 0 && price <= w.Threshold
+}
+
+// persisted state struct
+type state struct {
+	UpdateOffset   int                `json:"update_offset"`             // offset of the next update to receive
+	ChatCurrencies map[int64][]string `json:"chat_currencies,omitempty"` // chat id => currencies for displaying prices
+	ChatDefaults   map[int64]string   `json:"chat_defaults,omitempty"`   // chat id => default action for plain texts
+	ChatLanguages  map[int64]a.Lang   `json:"chat_languages,omitempty"`  // chat id => language overriding the one of users
+
+	LastCheckedPrices map[int64]map[collectionMode]priceRecord `json:"last_checked_prices,omitempty"` // chat id => collection mode => price of full collection when last summarized
+
+	KnownTypes         map[a.Lang][]string `json:"known_types,omitempty"`          // item types seen in the market so far
+	NewTypeSubscribers map[int64]a.Lang    `json:"new_type_subscribers,omitempty"` // chat id => language for notifications of new item types
+
+	DailySummarySubscribers map[int64]a.Lang `json:"daily_summary_subscribers,omitempty"` // chat id => language for daily summaries
+
+	CardTypes   map[a.Lang]map[string]string `json:"card_types,omitempty"`   // hash name => last seen type of the card
+	TypeChanges map[a.Lang][]typeChange      `json:"type_changes,omitempty"` // recent changes of cards' types
+
+	BlockedChats map[int64]time.Time `json:"blocked_chats,omitempty"` // chat id => time when the chat was found unreachable (eg. blocked the bot)
+
+	OwnedCards map[int64]map[string]int `json:"owned_cards,omitempty"` // chat id => hash name => number of owned cards
+
+	PriceWatches map[int64][]priceWatch `json:"price_watches,omitempty"` // chat id => watches of cards' prices
+
+	LowestCollectionPrice  *priceRecord `json:"lowest_collection_price,omitempty"`  // all-time lowest price of full collection
+	HighestCollectionPrice *priceRecord `json:"highest_collection_price,omitempty"` // all-time highest price of full collection
+}
+
+var _state state
+var _stateLock sync.Mutex
+var _savedUpdateOffset int // offset of updates in the state file
+
+// load persisted state (empty state on failure)
+func loadState() state {
+	var s state
+	if err := loadJSONFile(stateFilename, &s); err != nil {
+		if !os.IsNotExist(err) {
+			logWarnf("Failed to load state: %s", err)
+		}
+
+		return state{}
+	}
+
+	return s
+}
+
+// save current state to file (should be called while holding `_stateLock`)
+func saveState() {
+	if err := saveJSONFile(stateFilename, _state); err != nil {
+		logErrorf("Failed to save state: %s", err)
+		return
+	}
+
+	_savedUpdateOffset = _state.UpdateOffset
+}
+
+// load given JSON file next to the executable into `v`
+func loadJSONFile(filename string, v interface{}) error {
+	path, err := filepathNextToExecutable(filename)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(file, v)
+}
+
+// save `v` as given JSON file next to the executable
+//
+// (writes to a temporary file first, then replaces the old one with it)
+func saveJSONFile(filename string, v interface{}) error {
+	path, err := filepathNextToExecutable(filename)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, bytes, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// get the offset of updates to resume from
+func updateOffset() int {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	return _state.UpdateOffset
+}
+
+// set the offset of updates to resume from
+//
+// (kept in memory, and persisted periodically or with other changes of state, not on every update)
+func setUpdateOffset(offset int) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if offset > _state.UpdateOffset {
+		_state.UpdateOffset = offset
+	}
+}
+
+// persist the offset of updates if it was changed after the last save (returns true if saved)
+func flushUpdateOffset() bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.UpdateOffset == _savedUpdateOffset {
+		return false
+	}
+
+	saveState()
+
+	return true
+}
+
+// persist the offset of updates every `updateOffsetFlushSeconds`
+//
+// (also persisted on shutdown with other data)
+func runUpdateOffsetFlushes() {
+	for range time.Tick(updateOffsetFlushSeconds * time.Second) {
+		flushUpdateOffset()
+	}
+}
+
+// get currencies of given chat
+func currenciesOf(chatID int64) []string {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	return _state.ChatCurrencies[chatID]
+}
+
+// set currencies of given chat, and persist them
+func setCurrencies(chatID int64, currencies []string) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.ChatCurrencies == nil {
+		_state.ChatCurrencies = map[int64][]string{}
+	}
+	_state.ChatCurrencies[chatID] = currencies
+
+	saveState()
+}
+
+// mark given chat as unreachable, so that scheduled messages are not sent to it
+func markChatBlocked(chatID int64) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.BlockedChats == nil {
+		_state.BlockedChats = map[int64]time.Time{}
+	}
+	_state.BlockedChats[chatID] = time.Now()
+
+	saveState()
+}
+
+// unmark given chat as unreachable (eg. when a message is received from it again)
+func unmarkChatBlocked(chatID int64) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _, exists := _state.BlockedChats[chatID]; exists {
+		delete(_state.BlockedChats, chatID)
+
+		saveState()
+	}
+}
+
+// check if given chat was marked as unreachable
+func isChatBlocked(chatID int64) bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	_, exists := _state.BlockedChats[chatID]
+
+	return exists
+}
+
+// get cards owned by given chat (hash name => number of owned cards)
+func ownedCardsOf(chatID int64) map[string]int {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	owned := map[string]int{}
+	for hashName, count := range _state.OwnedCards[chatID] {
+		owned[hashName] = count
+	}
+
+	return owned
+}
+
+// add given number (negative for removing) of cards to the ones owned by given chat, and persist them
+//
+// returns the number of owned cards after the change
+func addOwnedCards(chatID int64, hashName string, delta int) int {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.OwnedCards == nil {
+		_state.OwnedCards = map[int64]map[string]int{}
+	}
+	if _state.OwnedCards[chatID] == nil {
+		_state.OwnedCards[chatID] = map[string]int{}
+	}
+
+	count := _state.OwnedCards[chatID][hashName] + delta
+	if count > 0 {
+		_state.OwnedCards[chatID][hashName] = count
+	} else {
+		count = 0
+		delete(_state.OwnedCards[chatID], hashName)
+		if len(_state.OwnedCards[chatID]) <= 0 {
+			delete(_state.OwnedCards, chatID)
+		}
+	}
+
+	saveState()
+
+	return count
+}
+
+// record given item types of given language, and return the ones which were not known before
+//
+// (nothing is returned when no type was known before, as it is the first observation)
+func recordItemTypes(language a.Lang, types []string) (newTypes []string) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.KnownTypes == nil {
+		_state.KnownTypes = map[a.Lang][]string{}
+	}
+	known := _state.KnownTypes[language]
+	seeding := len(known) <= 0
+
+	knownSet := map[string]struct{}{}
+	for _, itemType := range known {
+		knownSet[itemType] = struct{}{}
+	}
+
+	for _, itemType := range types {
+		if _, exists := knownSet[itemType]; !exists {
+			knownSet[itemType] = struct{}{}
+			known = append(known, itemType)
+
+			if !seeding {
+				newTypes = append(newTypes, itemType)
+			}
+		}
+	}
+
+	if seeding || len(newTypes) > 0 {
+		_state.KnownTypes[language] = known
+
+		saveState()
+	}
+
+	return newTypes
+}
+
+// subscribe given chat to notifications of new item types
+func subscribeNewTypes(chatID int64, language a.Lang) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.NewTypeSubscribers == nil {
+		_state.NewTypeSubscribers = map[int64]a.Lang{}
+	}
+	_state.NewTypeSubscribers[chatID] = language
+
+	saveState()
+}
+
+// unsubscribe given chat from notifications of new item types
+func unsubscribeNewTypes(chatID int64) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	delete(_state.NewTypeSubscribers, chatID)
+
+	saveState()
+}
+
+// subscribe given chat to daily summaries in given language
+func subscribeDailySummary(chatID int64, language a.Lang) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.DailySummarySubscribers == nil {
+		_state.DailySummarySubscribers = map[int64]a.Lang{}
+	}
+	_state.DailySummarySubscribers[chatID] = language
+
+	saveState()
+}
+
+// unsubscribe given chat from daily summaries
+//
+// (returns false when it was not subscribed)
+func unsubscribeDailySummary(chatID int64) bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _, exists := _state.DailySummarySubscribers[chatID]; !exists {
+		return false
+	}
+	delete(_state.DailySummarySubscribers, chatID)
+
+	saveState()
+
+	return true
+}
+
+// check if given chat is subscribed to daily summaries
+func isSubscribedToDailySummary(chatID int64) bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	_, exists := _state.DailySummarySubscribers[chatID]
+
+	return exists
+}
+
+// get chats subscribed to daily summaries (chat id => language)
+func dailySummarySubscribers() map[int64]a.Lang {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	subscribers := map[int64]a.Lang{}
+	for chatID, language := range _state.DailySummarySubscribers {
+		subscribers[chatID] = language
+	}
+
+	return subscribers
+}
+
+// get chats subscribed to notifications of new item types in given language
+func newTypeSubscribers(language a.Lang) (chatIDs []int64) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	for chatID, lang := range _state.NewTypeSubscribers {
+		if lang == language {
+			chatIDs = append(chatIDs, chatID)
+		}
+	}
+
+	return chatIDs
+}
+
+// record given price of full collection if it is the lowest or highest one so far
+func recordCollectionPrice(price int) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	record := &priceRecord{Price: price, Time: time.Now()}
+	updated := false
+
+	if _state.LowestCollectionPrice == nil || price < _state.LowestCollectionPrice.Price {
+		_state.LowestCollectionPrice = record
+		updated = true
+	}
+	if _state.HighestCollectionPrice == nil || price > _state.HighestCollectionPrice.Price {
+		_state.HighestCollectionPrice = record
+		updated = true
+	}
+
+	if updated {
+		saveState()
+	}
+}
+
+// get all-time lowest and highest prices of full collection (nil if not recorded yet)
+func collectionPriceExtremes() (lowest, highest *priceRecord) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	return _state.LowestCollectionPrice, _state.HighestCollectionPrice
+}
+
+// check if given chat is subscribed to notifications of new item types
+func isSubscribedToNewTypes(chatID int64) bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	_, exists := _state.NewTypeSubscribers[chatID]
+
+	return exists
+}
+
+// reset all settings of given chat
+func resetChatSettings(chatID int64) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	delete(_state.ChatCurrencies, chatID)
+	delete(_state.ChatDefaults, chatID)
+	delete(_state.ChatLanguages, chatID)
+	delete(_state.LastCheckedPrices, chatID)
+	delete(_state.NewTypeSubscribers, chatID)
+	delete(_state.DailySummarySubscribers, chatID)
+	delete(_state.OwnedCards, chatID)
+	delete(_state.PriceWatches, chatID)
+
+	saveState()
+}
+
+// get default action of given chat for plain texts
+func defaultActionOf(chatID int64) defaultAction {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if action, exists := _state.ChatDefaults[chatID]; exists {
+		return defaultAction(action)
+	}
+
+	return defaultActionNone
+}
+
+// set default action of given chat for plain texts, and persist it
+func setDefaultAction(chatID int64, action defaultAction) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if action == defaultActionNone {
+		delete(_state.ChatDefaults, chatID)
+	} else {
+		if _state.ChatDefaults == nil {
+			_state.ChatDefaults = map[int64]string{}
+		}
+		_state.ChatDefaults[chatID] = string(action)
+	}
+
+	saveState()
+}
+
+// get price watches of given chat
+func priceWatchesOf(chatID int64) []priceWatch {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	return append([]priceWatch{}, _state.PriceWatches[chatID]...)
+}
+
+// get price watches of all chats
+func allPriceWatches() map[int64][]priceWatch {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	watches := map[int64][]priceWatch{}
+	for chatID, w := range _state.PriceWatches {
+		watches[chatID] = append([]priceWatch{}, w...)
+	}
+
+	return watches
+}
+
+// add (or replace the one of the same card) price watch of given chat, and persist it
+//
+// (returns false when there are too many watches already)
+func addPriceWatch(chatID int64, watch priceWatch) bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.PriceWatches == nil {
+		_state.PriceWatches = map[int64][]priceWatch{}
+	}
+
+	watches := []priceWatch{}
+	for _, w := range _state.PriceWatches[chatID] {
+		if w.HashName != watch.HashName {
+			watches = append(watches, w)
+		}
+	}
+	if len(watches) >= maxNumPriceWatches {
+		return false
+	}
+	_state.PriceWatches[chatID] = append(watches, watch)
+
+	saveState()
+
+	return true
+}
+
+// remove price watch of given card from given chat, and persist it
+//
+// (returns false when there was no such watch)
+func removePriceWatch(chatID int64, hashName string) bool {
+	return removePriceWatchesMatching(chatID, func(w priceWatch) bool {
+		return w.HashName == hashName
+	})
+}
+
+// remove given price watch from given chat, and persist it
+//
+// (only the exact watch is removed, so a watch of the same card which replaced it meanwhile is kept)
+func removeExactPriceWatch(chatID int64, watch priceWatch) bool {
+	return removePriceWatchesMatching(chatID, func(w priceWatch) bool {
+		return w.HashName == watch.HashName && w.Threshold == watch.Threshold && w.Rising == watch.Rising && w.Created.Equal(watch.Created)
+	})
+}
+
+// remove price watches of given chat which match given function, and persist them
+//
+// (returns false when there was no matching watch)
+func removePriceWatchesMatching(chatID int64, matches func(w priceWatch) bool) bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	removed := false
+	watches := []priceWatch{}
+	for _, w := range _state.PriceWatches[chatID] {
+		if matches(w) {
+			removed = true
+		} else {
+			watches = append(watches, w)
+		}
+	}
+
+	if len(watches) > 0 {
+		_state.PriceWatches[chatID] = watches
+	} else {
+		delete(_state.PriceWatches, chatID)
+	}
+
+	if removed {
+		saveState()
+	}
+
+	return removed
+}
+
+// remove all price watches of given chat, and persist it
+//
+// (returns false when there was none)
+func removePriceWatches(chatID int64) bool {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _, exists := _state.PriceWatches[chatID]; !exists {
+		return false
+	}
+	delete(_state.PriceWatches, chatID)
+
+	saveState()
+
+	return true
+}
+
+// get language of given chat which overrides the one of users
+func chatLanguageOf(chatID int64) (language a.Lang, exists bool) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	language, exists = _state.ChatLanguages[chatID]
+
+	return language, exists
+}
+
+// set (or clear with nil) language of given chat, and persist it
+func setChatLanguage(chatID int64, language *a.Lang) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if language == nil {
+		delete(_state.ChatLanguages, chatID)
+	} else {
+		if _state.ChatLanguages == nil {
+			_state.ChatLanguages = map[int64]a.Lang{}
+		}
+		_state.ChatLanguages[chatID] = *language
+	}
+
+	saveState()
+}
+
+// record given price of full collection as the last checked one of given chat,
+// and return the previously checked one (nil if none)
+func swapLastCheckedPrice(chatID int64, mode collectionMode, price int) (previous *priceRecord) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.LastCheckedPrices == nil {
+		_state.LastCheckedPrices = map[int64]map[collectionMode]priceRecord{}
+	}
+	if _state.LastCheckedPrices[chatID] == nil {
+		_state.LastCheckedPrices[chatID] = map[collectionMode]priceRecord{}
+	}
+
+	if record, exists := _state.LastCheckedPrices[chatID][mode]; exists {
+		previous = &record
+	}
+	_state.LastCheckedPrices[chatID][mode] = priceRecord{Price: price, Time: time.Now()}
+
+	saveState()
+
+	return previous
+}
+
+// record types of given items, and return changes of them since the last record
+//
+// (nothing is returned when no type was recorded before, as it is the first observation)
+func recordCardTypes(language a.Lang, items []a.MarketItem) (changes []typeChange) {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	if _state.CardTypes == nil {
+		_state.CardTypes = map[a.Lang]map[string]string{}
+	}
+	if _state.TypeChanges == nil {
+		_state.TypeChanges = map[a.Lang][]typeChange{}
+	}
+	types, seeded := _state.CardTypes[language]
+	if !seeded {
+		types = map[string]string{}
+	}
+
+	updated := !seeded
+	for _, item := range items {
+		itemType := item.AssetDescription.Type
+
+		if previous, exists := types[item.HashName]; !exists || previous != itemType {
+			if exists {
+				changes = append(changes, typeChange{
+					Name: item.Name,
+					From: previous,
+					To:   itemType,
+					Time: time.Now(),
+				})
+			}
+
+			types[item.HashName] = itemType
+			updated = true
+		}
+	}
+	_state.CardTypes[language] = types
+
+	if len(changes) > 0 {
+		all := append(_state.TypeChanges[language], changes...)
+		if len(all) > maxNumTypeChanges {
+			all = all[len(all)-maxNumTypeChanges:]
+		}
+		_state.TypeChanges[language] = all
+	}
+
+	if updated {
+		saveState()
+	}
+
+	return changes
+}
+
+// get recent changes of cards' types (most recent first)
+func recentTypeChanges(language a.Lang) []typeChange {
+	_stateLock.Lock()
+	defer _stateLock.Unlock()
+
+	changes := []typeChange{}
+	for i := len(_state.TypeChanges[language]) - 1; i >= 0; i-- {
+		changes = append(changes, _state.TypeChanges[language][i])
+	}
+
+	return changes
+}
@@ -0,0 +1,48 @@
+package main
+
+// stats.go
+//
+// counters of handled messages, inline queries, and cache hits/misses, shown to admins
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// counters of handled things (accessed atomically)
+var _numMessages int64
+var _numInlineQueries int64
+var _numCacheHits int64
+var _numCacheMisses int64
+var _statsSince int64 = time.Now().UnixNano() // unix nanoseconds of when counting started
+
+// statistics of handled things
+type stats struct {
+	numMessages      int64
+	numInlineQueries int64
+	numCacheHits     int64
+	numCacheMisses   int64
+	since            time.Time
+}
+
+// get current statistics
+func currentStats() stats {
+	return stats{
+		numMessages:      atomic.LoadInt64(&_numMessages),
+		numInlineQueries: atomic.LoadInt64(&_numInlineQueries),
+		numCacheHits:     atomic.LoadInt64(&_numCacheHits),
+		numCacheMisses:   atomic.LoadInt64(&_numCacheMisses),
+		since:            time.Unix(0, atomic.LoadInt64(&_statsSince)),
+	}
+}
+
+// reset statistics, and return the ones before reset
+func resetStats() stats {
+	return stats{
+		numMessages:      atomic.SwapInt64(&_numMessages, 0),
+		numInlineQueries: atomic.SwapInt64(&_numInlineQueries, 0),
+		numCacheHits:     atomic.SwapInt64(&_numCacheHits, 0),
+		numCacheMisses:   atomic.SwapInt64(&_numCacheMisses, 0),
+		since:            time.Unix(0, atomic.SwapInt64(&_statsSince, time.Now().UnixNano())),
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+// telegram.go
+//
+// handling of errors from Telegram Bot API
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	t "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	// max seconds to retry after, when Telegram asks to retry later (messages are dropped over this)
+	maxRetryAfterSeconds = 30
+)
+
+// kinds of errors from Telegram Bot API
+type apiErrorKind int
+
+const (
+	apiErrorUnknown         apiErrorKind = iota
+	apiErrorTooManyRequests              // 429: "Too Many Requests: retry after N"
+	apiErrorForbidden                    // 403: "Forbidden: bot was blocked by the user", etc.
+)
+
+// error from Telegram Bot API
+type apiError struct {
+	kind        apiErrorKind
+	description string
+	retryAfter  time.Duration // for `apiErrorTooManyRequests`
+}
+
+func (e *apiError) Error() string {
+	return e.description
+}
+
+// sender of messages and answers through Telegram Bot API
+//
+// (satisfied by `*t.Bot`, and replaced with fakes in tests)
+type messageSender interface {
+	SendMessage(chatID t.ChatID, text string, options t.OptionsSendMessage) t.APIResponseMessage
+	SendPhoto(chatID t.ChatID, photoFile t.InputFile, options t.OptionsSendPhoto) t.APIResponseMessage
+	SendDocument(chatID t.ChatID, documentFile t.InputFile, options t.OptionsSendDocument) t.APIResponseMessage
+	SendChatAction(chatID t.ChatID, action t.ChatAction) t.APIResponseBool
+	AnswerInlineQuery(inlineQueryID string, results []interface{}, options t.OptionsAnswerInlineQuery) t.APIResponseBool
+	AnswerCallbackQuery(callbackQueryID string, options t.OptionsAnswerCallbackQuery) t.APIResponseBool
+	EditMessageText(text string, options t.OptionsEditMessageText) t.APIResponseMessageOrBool
+}
+
+var _retryAfterPattern = regexp.MustCompile(`(?i)retry after (\d+)`)
+
+// get description of given response (never panics on missing description)
+func descriptionOf(res t.APIResponseBase) string {
+	if res.Description != nil {
+		return *res.Description
+	}
+
+	return "no description"
+}
+
+// get error of given response (nil when it was successful)
+func apiErrorOf(res t.APIResponseBase) *apiError {
+	if res.Ok {
+		return nil
+	}
+
+	err := &apiError{
+		kind:        apiErrorUnknown,
+		description: descriptionOf(res),
+	}
+
+	// (responses of the bot library have only descriptions, so they are matched against known ones)
+	switch {
+	case strings.HasPrefix(err.description, "Too Many Requests"):
+		err.kind = apiErrorTooManyRequests
+		if matches := _retryAfterPattern.FindStringSubmatch(err.description); len(matches) > 1 {
+			seconds, _ := strconv.Atoi(matches[1])
+			err.retryAfter = time.Duration(seconds) * time.Second
+		}
+	case strings.HasPrefix(err.description, "Forbidden"):
+		err.kind = apiErrorForbidden
+	}
+
+	return err
+}
+
+// send something to given chat with given function, handling errors from Telegram Bot API
+//
+// - retries once later when Telegram asks to retry later (429), without blocking the caller
+// - handles the chat as blocked when the bot is not allowed to send messages to it (403)
+//
+// (used for all kinds of messages, eg. texts, photos, and documents)
+func sendHandlingErrors(chatID int64, send func() t.APIResponseBase) *apiError {
+	err := apiErrorOf(send())
+	if err != nil && err.kind == apiErrorTooManyRequests && err.retryAfter <= maxRetryAfterSeconds*time.Second {
+		logWarnf("Retrying to send message to chat %d after %s", chatID, err.retryAfter)
+
+		time.AfterFunc(err.retryAfter, func() {
+			if err := handleForbidden(chatID, apiErrorOf(send())); err != nil {
+				logErrorf("Failed to send message to chat %d after retrying: %s", chatID, err)
+			}
+		})
+
+		return nil // (scheduled)
+	}
+
+	return handleForbidden(chatID, err)
+}
+
+// handle the chat as blocked if given error is 403, and return the error back
+func handleForbidden(chatID int64, err *apiError) *apiError {
+	if err != nil && err.kind == apiErrorForbidden {
+		handleBlockedChat(chatID, err)
+	}
+
+	return err
+}
+
+// send a message, handling errors from Telegram Bot API
+//
+// (given message is written in Markdown, and converted for `parse_mode` in config)
+func sendMessage(b messageSender, chatID int64, message string, options t.OptionsSendMessage) error {
+	message = formatMessage(message)
+
+	if err := sendHandlingErrors(chatID, func() t.APIResponseBase {
+		return b.SendMessage(chatID, message, options).APIResponseBase
+	}); err != nil {
+		return fmt.Errorf("failed to send message to chat %d: %s", chatID, err)
+	}
+
+	return nil
+}
+
+// handle a chat which the bot cannot send messages to anymore (eg. the user blocked the bot)
+//
+// (cleans up its subscriptions, and stops scheduled messages until a message is received from it again)
+func handleBlockedChat(chatID int64, err *apiError) {
+	cleaned := []string{}
+
+	if isSubscribedToNewTypes(chatID) {
+		unsubscribeNewTypes(chatID)
+		cleaned = append(cleaned, "notifications of new types")
+	}
+
+	if unsubscribeDailySummary(chatID) {
+		cleaned = append(cleaned, "daily summaries")
+	}
+
+	if removePriceWatches(chatID) {
+		cleaned = append(cleaned, "price watches")
+	}
+
+	if !isChatBlocked(chatID) {
+		markChatBlocked(chatID)
+		cleaned = append(cleaned, "scheduled messages")
+	}
+
+	if len(cleaned) > 0 {
+		logInfof("Chat %d is not reachable (%s), cleaned up: %s", chatID, err, strings.Join(cleaned, ", "))
+	}
+}
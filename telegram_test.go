@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+	t "github.com/meinside/telegram-bot-go"
+)
+
+// get a failed response with given description
+func failedResponse(description string) t.APIResponseBase {
+	return t.APIResponseBase{Ok: false, Description: &description}
+}
+
+// test mapping responses to errors
+func TestAPIErrorOf(test *testing.T) {
+	for _, c := range []struct {
+		name       string
+		res        t.APIResponseBase
+		isNil      bool
+		kind       apiErrorKind
+		retryAfter time.Duration
+	}{
+		{"ok", t.APIResponseBase{Ok: true}, true, apiErrorUnknown, 0},
+		{"retry after", failedResponse("Too Many Requests: retry after 5"), false, apiErrorTooManyRequests, 5 * time.Second},
+		{"retry after over the limit", failedResponse("Too Many Requests: retry after 3600"), false, apiErrorTooManyRequests, 3600 * time.Second},
+		{"too many requests without retry after", failedResponse("Too Many Requests"), false, apiErrorTooManyRequests, 0},
+		{"blocked", failedResponse("Forbidden: bot was blocked by the user"), false, apiErrorForbidden, 0},
+		{"kicked", failedResponse("Forbidden: bot was kicked from the group chat"), false, apiErrorForbidden, 0},
+		{"nil description", t.APIResponseBase{Ok: false}, false, apiErrorUnknown, 0},
+		{"unknown", failedResponse("Bad Request: chat not found"), false, apiErrorUnknown, 0},
+	} {
+		err := apiErrorOf(c.res)
+
+		if c.isNil {
+			if err != nil {
+				test.Errorf("%s: expected no error, got: %s", c.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			test.Errorf("%s: expected an error", c.name)
+			continue
+		}
+		if err.kind != c.kind || err.retryAfter != c.retryAfter {
+			test.Errorf("%s: expected (%d, %s), got (%d, %s)", c.name, c.kind, c.retryAfter, err.kind, err.retryAfter)
+		}
+		if err.Error() == "" {
+			test.Errorf("%s: expected a description", c.name)
+		}
+	}
+}
+
+// test handling of 429 and 403 errors while sending messages
+func TestSendMessageWithErrors(test *testing.T) {
+	for _, c := range []struct {
+		name     string
+		failures []t.APIResponseBase
+		sent     bool
+		blocked  bool
+	}{
+		{"sent", nil, true, false},
+		{"retried after 429", []t.APIResponseBase{failedResponse("Too Many Requests: retry after 0")}, true, false},
+		{"not retried when retry after is over the limit", []t.APIResponseBase{failedResponse("Too Many Requests: retry after 3600")}, false, false},
+		{"retried only once", []t.APIResponseBase{failedResponse("Too Many Requests: retry after 0"), failedResponse("Too Many Requests: retry after 0")}, false, false},
+		{"blocked with 403", []t.APIResponseBase{failedResponse("Forbidden: bot was blocked by the user")}, false, true},
+	} {
+		s := setUpTest(test, config{}, _testItems)
+		s.failures = c.failures
+
+		subscribeDailySummary(testChatID, a.LangEnglish)
+
+		err := sendMessage(s, testChatID, "test", t.OptionsSendMessage{})
+		waitForRetries(s)
+
+		if sent := err == nil && len(s.texts()) == 1; sent != c.sent {
+			test.Errorf("%s: expected sent to be %t, got %t (%v)", c.name, c.sent, sent, err)
+		}
+		if blocked := isChatBlocked(testChatID); blocked != c.blocked {
+			test.Errorf("%s: expected blocked to be %t, got %t", c.name, c.blocked, blocked)
+		}
+		if subscribed := isSubscribedToDailySummary(testChatID); subscribed == c.blocked {
+			test.Errorf("%s: expected subscriptions to be cleaned up only when blocked", c.name)
+		}
+	}
+}
+
+// test that only successful polls of updates are marked for the watchdog
+func TestPollingTransport(test *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "fail") {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	client := http.Client{Transport: pollingTransport{http.DefaultTransport}}
+
+	for _, c := range []struct {
+		path   string
+		marked bool
+	}{
+		{"/bottoken/getUpdates", true},
+		{"/bottoken/sendMessage", false},
+		{"/bottoken-fail/getUpdates", false},
+	} {
+		atomic.StoreInt64(&_lastPolled, 0)
+
+		res, err := client.Get(server.URL + c.path)
+		if err != nil {
+			test.Fatalf("%s: request failed: %s", c.path, err)
+		}
+		res.Body.Close()
+
+		if marked := !lastPolled().Equal(time.Unix(0, 0)); marked != c.marked {
+			test.Errorf("%s: expected marked %t, got %t", c.path, c.marked, marked)
+		}
+	}
+}
+
+// wait for scheduled retries (after 0 seconds) of given sender
+func waitForRetries(s *fakeSender) {
+	for i := 0; i < 100 && s.numFailures() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// (for the retried send to finish)
+	time.Sleep(10 * time.Millisecond)
+}
+
+// test that 429 is retried without blocking the sender
+func TestSendMessageRetriedLater(test *testing.T) {
+	s := setUpTest(test, config{}, _testItems)
+	s.failures = []t.APIResponseBase{failedResponse("Too Many Requests: retry after 1")}
+
+	started := time.Now()
+	if err := sendMessage(s, testChatID, "test", t.OptionsSendMessage{}); err != nil {
+		test.Fatalf("expected the retry to be scheduled, got: %s", err)
+	}
+	if elapsed := time.Since(started); elapsed >= time.Second {
+		test.Errorf("expected not to wait for the retry, but waited for %s", elapsed)
+	}
+	if texts := s.texts(); len(texts) != 0 {
+		test.Errorf("expected no message before the retry, got: %v", texts)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if texts := s.texts(); len(texts) != 1 {
+		test.Errorf("expected the message to be sent after the retry, got: %v", texts)
+	}
+}
+
+// test that errors of sending files are handled like the ones of sending messages
+func TestSendFilesWithErrors(test *testing.T) {
+	for _, c := range []struct {
+		name     string
+		failures []t.APIResponseBase
+		sent     bool
+		blocked  bool
+	}{
+		{"sent", nil, true, false},
+		{"retried after 429", []t.APIResponseBase{failedResponse("Too Many Requests: retry after 0")}, true, false},
+		{"blocked with 403", []t.APIResponseBase{failedResponse("Forbidden: bot was blocked by the user")}, false, true},
+	} {
+		s := setUpTest(test, config{}, _testItems)
+		s.failures = c.failures
+
+		message := sendExport(s, testChatID, "csv", a.LangEnglish)
+		waitForRetries(s)
+
+		if sent := message == "" && s.numSentFiles() == 1; sent != c.sent {
+			test.Errorf("%s: expected sent to be %t, got %t (%s)", c.name, c.sent, sent, message)
+		}
+		if blocked := isChatBlocked(testChatID); blocked != c.blocked {
+			test.Errorf("%s: expected blocked to be %t, got %t", c.name, c.blocked, blocked)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+// textimage.go
+//
+// render texts (eg. help messages, or long summaries and lists) as images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	textImageWidth         = 960
+	textImagePadding       = 40
+	textImageTitleSize     = 32
+	textImageBodySize      = 20
+	textImageLineSpacing   = 1.5
+	textImageHeaderSpacing = 2.5
+)
+
+var (
+	textImageBackgroundColor = color.RGBA{0xfa, 0xfa, 0xfa, 0xff}
+	textImageHeaderColor     = color.RGBA{0x2c, 0x3e, 0x50, 0xff}
+	textImageTitleColor      = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	textImageCommandColor    = color.RGBA{0xc0, 0x39, 0x2b, 0xff}
+	textImageTextColor       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+)
+
+// a line of text image
+type textImageLine struct {
+	command     string // drawn in accent color (can be empty)
+	description string
+}
+
+// render given markdown text as a png image, with its first line as the title
+//
+// (needs a truetype font with glyphs of all languages, configured with `text_image_font_path`)
+func renderTextImage(text string) ([]byte, error) {
+	parsed, err := loadImageFont()
+	if err != nil {
+		return nil, err
+	}
+	titleFace := truetype.NewFace(parsed, &truetype.Options{Size: textImageTitleSize})
+	bodyFace := truetype.NewFace(parsed, &truetype.Options{Size: textImageBodySize})
+
+	// text without markdown
+	text = markdownToText(text)
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	title, lines := strings.TrimSuffix(lines[0], ":"), lines[1:]
+
+	// wrap lines to fit in the image
+	maxWidth := fixed.I(textImageWidth - textImagePadding*2)
+	wrapped := []textImageLine{}
+	for _, line := range lines {
+		var command string
+		if strings.HasPrefix(line, "/") {
+			if i := strings.Index(line, ":"); i > 0 {
+				command, line = line[:i+1], line[i+1:]
+			}
+		}
+
+		commandWidth := font.MeasureString(bodyFace, command)
+		for _, l := range wrapText(bodyFace, line, maxWidth-commandWidth) {
+			wrapped = append(wrapped, textImageLine{command: command, description: l})
+			command, commandWidth = "", 0
+		}
+	}
+
+	titleHeight := int(textImageTitleSize * textImageHeaderSpacing)
+	lineHeight := int(textImageBodySize * textImageLineSpacing)
+	height := titleHeight + textImagePadding*2 + lineHeight*len(wrapped)
+
+	img := image.NewRGBA(image.Rect(0, 0, textImageWidth, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(textImageBackgroundColor), image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, textImageWidth, titleHeight), image.NewUniform(textImageHeaderColor), image.ZP, draw.Src)
+
+	// title
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(textImageTitleColor),
+		Face: titleFace,
+		Dot:  fixed.P(textImagePadding, (titleHeight+textImageTitleSize)/2),
+	}
+	drawer.DrawString(title)
+
+	// lines (commands in accent color)
+	drawer.Face = bodyFace
+	for i, line := range wrapped {
+		drawer.Dot = fixed.P(textImagePadding, titleHeight+textImagePadding+lineHeight*(i+1))
+
+		if line.command != "" {
+			drawer.Src = image.NewUniform(textImageCommandColor)
+			drawer.DrawString(line.command)
+		}
+		drawer.Src = image.NewUniform(textImageTextColor)
+		drawer.DrawString(line.description)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// load the truetype font for drawing texts in images
+func loadImageFont() (*truetype.Font, error) {
+	fontPath := conf().TextImageFontPath
+	if fontPath == "" {
+		return nil, fmt.Errorf("font for images is not configured")
+	}
+
+	fontBytes, err := ioutil.ReadFile(fontPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return truetype.Parse(fontBytes)
+}
+
+// wrap given text into lines which fit in given width (by words)
+func wrapText(face font.Face, text string, width fixed.Int26_6) []string {
+	lines := []string{}
+
+	line := ""
+	for _, word := range strings.SplitAfter(text, " ") {
+		if line != "" && font.MeasureString(face, strings.TrimRight(line+word, " ")) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		line += word
+	}
+
+	return append(lines, line)
+}
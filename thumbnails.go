@@ -0,0 +1,133 @@
+package main
+
+// thumbnails.go
+//
+// thumbnails of inline query results are validated (and cached), so that broken ones are not shown
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// time limit of validating thumbnails for an inline query
+	thumbnailValidationTimeoutMillis = 700
+
+	// timeout of each validation request
+	thumbnailRequestTimeoutSeconds = 5
+
+	// ttl of thumbnail validation results
+	thumbnailCacheMinutes = 60
+
+	// max number of concurrent validation requests (shared by all inline queries)
+	maxNumThumbnailValidations = 8
+)
+
+// result of validating a thumbnail
+type thumbnailValidation struct {
+	valid     bool
+	validated time.Time
+}
+
+var _thumbnails = map[string]thumbnailValidation{}
+var _thumbnailsValidating = map[string]bool{} // urls being validated now
+var _thumbnailsLock sync.RWMutex
+
+// semaphore for limiting concurrent validation requests
+var _thumbnailValidations = make(chan struct{}, maxNumThumbnailValidations)
+
+// get cached validation result of given thumbnail url
+func cachedThumbnailValidation(url string) (valid, exists bool) {
+	_thumbnailsLock.RLock()
+	defer _thumbnailsLock.RUnlock()
+
+	if validation, exists := _thumbnails[url]; exists && time.Since(validation.validated) < thumbnailCacheMinutes*time.Minute {
+		return validation.valid, true
+	}
+
+	return false, false
+}
+
+// mark given thumbnail url as being validated (false if it is already being validated)
+func startValidatingThumbnail(url string) bool {
+	_thumbnailsLock.Lock()
+	defer _thumbnailsLock.Unlock()
+
+	if _thumbnailsValidating[url] {
+		return false
+	}
+	_thumbnailsValidating[url] = true
+
+	return true
+}
+
+// validate given thumbnail url with a HEAD request, and cache the result
+//
+// (waits while `maxNumThumbnailValidations` requests are running)
+func validateThumbnail(client *http.Client, url string) bool {
+	_thumbnailValidations <- struct{}{}
+	defer func() { <-_thumbnailValidations }()
+
+	valid := false
+	if res, err := client.Head(url); err == nil {
+		res.Body.Close()
+
+		valid = res.StatusCode == http.StatusOK
+	}
+
+	_thumbnailsLock.Lock()
+	_thumbnails[url] = thumbnailValidation{valid: valid, validated: time.Now()}
+	delete(_thumbnailsValidating, url)
+	_thumbnailsLock.Unlock()
+
+	return valid
+}
+
+// get invalid ones among given thumbnail urls
+//
+// validations not finished in time are skipped (treated as valid),
+// but their results are still cached for later queries
+// (so are the ones already being validated for other queries)
+func invalidThumbnails(urls []string) map[string]bool {
+	invalid := map[string]bool{}
+
+	type result struct {
+		url   string
+		valid bool
+	}
+	results := make(chan result, len(urls))
+	client := &http.Client{Timeout: thumbnailRequestTimeoutSeconds * time.Second}
+
+	numValidating := 0
+	for _, url := range urls {
+		if valid, exists := cachedThumbnailValidation(url); exists {
+			if !valid {
+				invalid[url] = true
+			}
+			continue
+		}
+		if !startValidatingThumbnail(url) {
+			continue
+		}
+
+		numValidating++
+		go func(url string) {
+			results <- result{url: url, valid: validateThumbnail(client, url)}
+		}(url)
+	}
+
+	timeout := time.After(thumbnailValidationTimeoutMillis * time.Millisecond)
+	for i := 0; i < numValidating; i++ {
+		select {
+		case r := <-results:
+			if !r.valid {
+				invalid[r.url] = true
+			}
+		case <-timeout:
+			return invalid
+		}
+	}
+
+	return invalid
+}
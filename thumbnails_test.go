@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// test that validations of thumbnails are bounded in concurrency, and not repeated for the same urls
+func TestInvalidThumbnails(test *testing.T) {
+	var numRequests, numRunning, maxNumRunning int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		running := atomic.AddInt32(&numRunning, 1)
+		defer atomic.AddInt32(&numRunning, -1)
+		for {
+			max := atomic.LoadInt32(&maxNumRunning)
+			if running <= max || atomic.CompareAndSwapInt32(&maxNumRunning, max, running) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	_thumbnailsLock.Lock()
+	_thumbnails = map[string]thumbnailValidation{}
+	_thumbnailsValidating = map[string]bool{}
+	_thumbnailsLock.Unlock()
+
+	urls := []string{}
+	for i := 0; i < maxNumThumbnailValidations*3; i++ {
+		urls = append(urls, fmt.Sprintf("%s/%d", server.URL, i))
+	}
+	urls = append(urls, server.URL+"/missing")
+
+	// (concurrent queries with the same thumbnails)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			invalidThumbnails(urls)
+		}()
+	}
+	wg.Wait()
+
+	// wait for the remaining validations, if any
+	for i := 0; i < 100 && atomic.LoadInt32(&numRunning) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&numRequests); int(n) != len(urls) {
+		test.Errorf("expected %d requests, got %d", len(urls), n)
+	}
+	if max := atomic.LoadInt32(&maxNumRunning); max > maxNumThumbnailValidations {
+		test.Errorf("expected at most %d concurrent requests, got %d", maxNumThumbnailValidations, max)
+	}
+
+	if invalid := invalidThumbnails(urls); len(invalid) != 1 || !invalid[server.URL+"/missing"] {
+		test.Errorf("expected only the missing thumbnail to be invalid (from cache), got: %v", invalid)
+	}
+}
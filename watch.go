@@ -0,0 +1,198 @@
+package main
+
+// watch.go
+//
+// watches of cards' prices, which alert chats when the prices cross given thresholds
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	a "github.com/meinside/steam-community-market-artifact"
+	t "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	// max number of price watches per chat
+	maxNumPriceWatches = 10
+
+	// default interval of checking price watches
+	defaultWatchIntervalMinutes = 10
+
+	messageWatchUsageEng   = "Usage: %s [card name] [price in USD]\n(e.g. %s Axe 5)"
+	messageWatchUsageKor   = "사용법: %s [카드 이름] [USD 가격]\n(예: %s 도끼 5)"
+	messageUnwatchUsageEng = "Usage: %s [card name]"
+	messageUnwatchUsageKor = "사용법: %s [카드 이름]"
+	messageWatchesEng      = "*Watched prices:*\n\n%s"
+	messageWatchesKor      = "*가격 알림 목록:*\n\n%s"
+	messageWatchesNoneEng  = "No watched prices.\n(to add one: %s [card name] [price in USD])"
+	messageWatchesNoneKor  = "가격 알림이 없습니다.\n(추가하려면: %s [카드 이름] [USD 가격])"
+	messageWatchRisingEng  = "- %s: rises to $%.2f (now: %s)"
+	messageWatchRisingKor  = "- %s: $%.2f 이상으로 오르면 (현재: %s)"
+	messageWatchFallingEng = "- %s: falls to $%.2f (now: %s)"
+	messageWatchFallingKor = "- %s: $%.2f 이하로 내리면 (현재: %s)"
+	messageWatchReachedEng = " *(reached)*"
+	messageWatchReachedKor = " *(도달)*"
+	messageWatchAddedEng   = "You will be alerted once when the price of %s %s $%.2f. (now: %s)"
+	messageWatchAddedKor   = "%s의 가격이 $%.2f %s 한 번 알려드립니다. (현재: %s)"
+	messageWatchRisesEng   = "rises to"
+	messageWatchRisesKor   = "이상으로 오르면"
+	messageWatchFallsEng   = "falls to"
+	messageWatchFallsKor   = "이하로 내리면"
+	messageWatchTooManyEng = "Only up to %d prices can be watched. Remove some with %s first."
+	messageWatchTooManyKor = "가격 알림은 최대 %d개까지 가능합니다. 먼저 %s 명령으로 삭제해 주세요."
+	messageUnwatchedEng    = "Stopped watching the price of %s."
+	messageUnwatchedKor    = "%s의 가격 알림을 중지했습니다."
+	messageUnwatchNoneEng  = "No watched price of '%s'."
+	messageUnwatchNoneKor  = "'%s'에 대한 가격 알림이 없습니다."
+	messageWatchAlertEng   = "*Price alert:* %s is now *%s* (threshold: $%.2f)"
+	messageWatchAlertKor   = "*가격 알림:* %s의 현재 가격은 *%s* 입니다 (기준: $%.2f)"
+)
+
+// get interval of checking price watches
+func watchInterval() time.Duration {
+	if minutes := conf().WatchIntervalMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+
+	return defaultWatchIntervalMinutes * time.Minute
+}
+
+// list price watches of given chat, or add one with given command argument
+func manageWatches(chatID int64, arg string, language a.Lang) (string, *t.InlineKeyboardMarkup) {
+	usage := fmt.Sprintf(localized(language, messageWatchUsageEng, messageWatchUsageKor), commandWatch, commandWatch)
+
+	args := strings.Fields(arg)
+
+	// list watches
+	if len(args) <= 0 {
+		return listWatches(chatID, language)
+	}
+	if len(args) < 2 {
+		return usage, nil
+	}
+
+	threshold, err := parsePrice(args[len(args)-1])
+	if err != nil || threshold <= 0 {
+		return usage, nil
+	}
+	query := sanitizeQuery(strings.Join(args[:len(args)-1], " "))
+	if query == "" {
+		return usage, nil
+	}
+
+	item, message := resolveSingleItem(query, language)
+	if message != "" {
+		return message, nil
+	}
+
+	watch := priceWatch{
+		HashName:  item.HashName,
+		Name:      item.Name,
+		Language:  language,
+		Threshold: threshold,
+		Rising:    item.SellPrice < threshold,
+		Created:   time.Now(),
+	}
+	if !addPriceWatch(chatID, watch) {
+		return fmt.Sprintf(localized(language, messageWatchTooManyEng, messageWatchTooManyKor), maxNumPriceWatches, commandUnwatch), nil
+	}
+
+	dollars := float32(threshold) / 100.0
+	if localizedLanguageOf(language) == a.LangKorean {
+		direction := messageWatchFallsKor
+		if watch.Rising {
+			direction = messageWatchRisesKor
+		}
+		return fmt.Sprintf(messageWatchAddedKor, escapeMarkdown(item.Name), dollars, direction, item.SellPriceText), nil
+	}
+	direction := messageWatchFallsEng
+	if watch.Rising {
+		direction = messageWatchRisesEng
+	}
+	return fmt.Sprintf(messageWatchAddedEng, escapeMarkdown(item.Name), direction, dollars, item.SellPriceText), nil
+}
+
+// list price watches of given chat with their current prices, flagging the ones whose thresholds are already reached
+func listWatches(chatID int64, language a.Lang) (string, *t.InlineKeyboardMarkup) {
+	watches := priceWatchesOf(chatID)
+	if len(watches) <= 0 {
+		return fmt.Sprintf(localized(language, messageWatchesNoneEng, messageWatchesNoneKor), commandWatch), nil
+	}
+
+	lines := []string{}
+	for _, watch := range watches {
+		current, reached := "-", false
+		if item, exists := itemByHashName(watch.HashName, watch.Language); exists {
+			current, reached = item.SellPriceText, watch.crossed(item.SellPrice)
+		}
+
+		format := localized(language, messageWatchFallingEng, messageWatchFallingKor)
+		if watch.Rising {
+			format = localized(language, messageWatchRisingEng, messageWatchRisingKor)
+		}
+		line := fmt.Sprintf(format, escapeMarkdown(watch.Name), float32(watch.Threshold)/100.0, current)
+		if reached {
+			line += localized(language, messageWatchReachedEng, messageWatchReachedKor)
+		}
+		lines = append(lines, line)
+	}
+
+	return paginate(localized(language, messageWatchesEng, messageWatchesKor), lines, language)
+}
+
+// remove price watch of given chat with given command argument
+func unwatch(chatID int64, arg string, language a.Lang) string {
+	query := sanitizeQuery(arg)
+	if query == "" {
+		return fmt.Sprintf(localized(language, messageUnwatchUsageEng, messageUnwatchUsageKor), commandUnwatch)
+	}
+
+	// match names of watched cards first,
+	for _, watch := range priceWatchesOf(chatID) {
+		if strings.EqualFold(watch.Name, query) && removePriceWatch(chatID, watch.HashName) {
+			return fmt.Sprintf(localized(language, messageUnwatchedEng, messageUnwatchedKor), escapeMarkdown(watch.Name))
+		}
+	}
+
+	// then search for the card
+	if item, _, found := resolveItem(query, language); found && removePriceWatch(chatID, item.HashName) {
+		return fmt.Sprintf(localized(language, messageUnwatchedEng, messageUnwatchedKor), escapeMarkdown(item.Name))
+	}
+
+	return fmt.Sprintf(localized(language, messageUnwatchNoneEng, messageUnwatchNoneKor), escapeMarkdown(query))
+}
+
+// periodically check price watches, and alert chats of crossed ones
+//
+// (alerted watches are removed, so each watch alerts only once)
+func runPriceWatches() {
+	for {
+		time.Sleep(watchInterval())
+
+		if _client != nil {
+			checkPriceWatches(_client)
+		}
+	}
+}
+
+// check price watches of all chats, and alert chats of crossed ones
+func checkPriceWatches(b messageSender) {
+	for chatID, watches := range allPriceWatches() {
+		for _, watch := range watches {
+			item, exists := itemByHashName(watch.HashName, watch.Language)
+			if !exists || !watch.crossed(item.SellPrice) {
+				continue
+			}
+
+			message := fmt.Sprintf(localized(watch.Language, messageWatchAlertEng, messageWatchAlertKor), escapeMarkdown(item.Name), item.SellPriceText, float32(watch.Threshold)/100.0)
+			if err := sendMessage(b, chatID, message, getMessageOptions()); err != nil {
+				logErrorf("Failed to send price alert: %s", err)
+				continue
+			}
+
+			removeExactPriceWatch(chatID, watch)
+		}
+	}
+}
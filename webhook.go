@@ -0,0 +1,134 @@
+package main
+
+// webhook.go
+//
+// receive updates through a webhook instead of long-polling
+//
+// (updates are served with plain HTTP on `webhook_port`, so it is expected to be
+// placed behind a reverse proxy which terminates TLS for `webhook_url`)
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	t "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	defaultWebhookPublicPort = 443
+
+	// timeout of waiting for updates being handled on shutdown
+	webhookShutdownTimeoutSeconds = 10
+)
+
+// parse and validate given webhook url, and return its host and port
+func parseWebhookURL(webhookURL string) (host string, port int, err error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", 0, err
+	}
+	if u.Scheme != "https" {
+		return "", 0, fmt.Errorf("webhook url should be https: '%s'", webhookURL)
+	}
+	if u.Hostname() == "" {
+		return "", 0, fmt.Errorf("no host in webhook url: '%s'", webhookURL)
+	}
+
+	port = defaultWebhookPublicPort
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return "", 0, err
+		}
+	}
+
+	return u.Hostname(), port, nil
+}
+
+// path of webhook (same as the one registered by telegram-bot-go: "/[token]")
+func webhookPath() string {
+	return "/" + conf().Token
+}
+
+// register webhook and serve updates (blocks until given context is canceled or the server fails)
+func startWebhook(ctx context.Context, b *t.Bot) error {
+	host, port, err := parseWebhookURL(conf().WebhookURL)
+	if err != nil {
+		return err
+	}
+
+	if hooked := b.SetWebhook(host, port, conf().WebhookCertPath); !hooked.Ok {
+		return fmt.Errorf("failed to set webhook: %s", descriptionOf(hooked.APIResponseBase))
+	}
+
+	var handling sync.WaitGroup // updates being handled
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(webhookPath(), func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var update t.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			logWarnf("Error while receiving update through webhook (%s)", err)
+
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		// (handled after responding, so that Telegram does not time out and deliver the same update again)
+		handling.Add(1)
+		go func() {
+			defer handling.Done()
+
+			handleUpdate(b, update)
+		}()
+	})
+
+	logInfof("Serving webhook on port %d for %s", conf().WebhookPort, conf().WebhookURL)
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", conf().WebhookPort),
+		Handler: mux,
+	}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+
+		<-ctx.Done()
+
+		// stop accepting updates
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeoutSeconds*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logErrorf("Failed to shut down webhook server: %s", err)
+		}
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	<-shutdown
+
+	// and wait for the ones being handled
+	handled := make(chan struct{})
+	go func() {
+		handling.Wait()
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(webhookShutdownTimeoutSeconds * time.Second):
+		logWarnf("Timed out while waiting for updates being handled")
+	}
+
+	return nil
+}